@@ -1,83 +1,138 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
 
+	"github.com/netco-crawler/internal/archive"
+	"github.com/netco-crawler/internal/config"
 	"github.com/netco-crawler/internal/crawler"
+	"github.com/netco-crawler/internal/database"
 	"github.com/netco-crawler/internal/models"
-)
-
-const (
-	htmlDir        = "./respone"
-	documentsDir   = "./static/documents"
-	baseNetcoURL   = "https://www.netcovn.com.vn"
-	dataOutputFile = "./static/data.json"
+	"github.com/netco-crawler/internal/utils"
 )
 
 func main() {
-	// Kiểm tra thư mục HTML
-	if _, err := os.Stat(htmlDir); os.IsNotExist(err) {
-		log.Fatalf("Thư mục HTML không tồn tại: %s", htmlDir)
+	// Nạp cấu hình từ tệp, biến môi trường và cờ dòng lệnh
+	mode := flag.String("mode", "live", "Crawl mode: live, record (save every HTTP response to -archive) or replay (serve from -archive without network)")
+	archiveDir := flag.String("archive", "./data/archive", "HTTP archive directory for record and replay modes")
+	cfg, err := config.NewLoader(flag.CommandLine).Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Lỗi cấu hình: %v", err)
+	}
+
+	opts, err := archiveOptions(*mode, *archiveDir, cfg)
+	if err != nil {
+		log.Fatalf("Lỗi cấu hình: %v", err)
 	}
 
 	// Đảm bảo thư mục đích tồn tại
-	if err := os.MkdirAll(documentsDir, 0755); err != nil {
+	if err := os.MkdirAll(cfg.DocumentsDir, 0755); err != nil {
 		log.Fatalf("Không thể tạo thư mục đích: %v", err)
 	}
 
+	// Hủy toàn bộ quá trình thu thập khi nhận SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Mở cơ sở dữ liệu, lần đầu chạy sẽ nhập data.json của phiên bản cũ
+	db, err := cfg.OpenDatabase(ctx)
+	if err != nil {
+		log.Fatalf("Lỗi cơ sở dữ liệu: %v", err)
+	}
+	defer db.Close()
+
+	run, err := db.StartRun(ctx)
+	if err != nil {
+		log.Fatalf("Lỗi cơ sở dữ liệu: %v", err)
+	}
+	// Ghi nhận lần chạy thất bại trước khi thoát vì lỗi
+	fail := func(format string, err error) {
+		run.Finish(context.Background(), database.RunFailed, 0, err)
+		log.Fatalf(format, err)
+	}
+
 	// Tạo crawler
-	c := crawler.NewCrawler(htmlDir, documentsDir, baseNetcoURL)
+	c, err := crawler.NewCrawlerFromConfig(cfg, append(opts, crawler.WithDatabase(db, run))...)
+	if err != nil {
+		fail("Lỗi tạo crawler: %v", err)
+	}
 
 	// Xử lý tệp HTML
 	log.Println("Bắt đầu phân tích các tệp HTML...")
-	if err := c.ProcessHTMLFiles(); err != nil {
-		log.Fatalf("Lỗi khi xử lý tệp HTML: %v", err)
+	if err := c.ProcessHTMLFilesContext(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		fail("Lỗi khi xử lý tệp HTML: %v", err)
 	}
 
 	// Tải xuống tài liệu
-	log.Println("Bắt đầu tải xuống tài liệu...")
-	if err := c.DownloadDocuments(); err != nil {
-		log.Fatalf("Lỗi khi tải xuống tài liệu: %v", err)
+	if ctx.Err() == nil {
+		log.Println("Bắt đầu tải xuống tài liệu...")
+		if err := c.DownloadDocumentsContext(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			fail("Lỗi khi tải xuống tài liệu: %v", err)
+		}
 	}
 
-	// Xuất dữ liệu sang JSON
-	if err := saveDataToJSON(c.GetDocuments(), dataOutputFile); err != nil {
-		log.Fatalf("Lỗi khi lưu dữ liệu vào JSON: %v", err)
+	c.Close()
+
+	// Lưu vào cơ sở dữ liệu và xuất data.json, kể cả khi bị hủy giữa chừng
+	if err := run.SaveAndExport(ctx, c.Result(ctx.Err()), cfg.DataFile); err != nil {
+		log.Fatalf("Lỗi khi lưu dữ liệu: %v", err)
+	}
+
+	if ctx.Err() != nil {
+		log.Println("Đã dừng theo yêu cầu, dữ liệu thu thập được đã được lưu vào", cfg.Database)
+		os.Exit(1)
 	}
 
 	// In thống kê
 	printStats(c.GetDocuments())
-
-	log.Println("Hoàn tất! Các tài liệu đã được lưu trong", documentsDir)
-}
-
-// saveDataToJSON lưu dữ liệu vào tệp JSON
-func saveDataToJSON(docs map[string][]models.Document, outputPath string) error {
-	// Đảm bảo thư mục đích tồn tại
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return fmt.Errorf("không thể tạo thư mục đích: %w", err)
+	if warnings := c.ParseWarnings(); len(warnings) > 0 {
+		log.Printf("Có %d cảnh báo khi phân tích bảng danh sách, kiểm tra lại nhãn tiêu đề (column_labels)", len(warnings))
 	}
-
-	// Tạo tệp đích
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("không thể tạo tệp JSON: %w", err)
+	if pageErrors := c.PageErrors(); len(pageErrors) > 0 {
+		log.Printf("Có %d trang danh sách không tải được, lần chạy được lưu ở trạng thái %s", len(pageErrors), database.RunPartial)
 	}
-	defer file.Close()
 
-	// Encode dữ liệu thành JSON
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(docs); err != nil {
-		return fmt.Errorf("không thể encode dữ liệu thành JSON: %w", err)
-	}
+	log.Println("Hoàn tất! Các tài liệu đã được lưu trong", cfg.DocumentsDir)
+}
 
-	log.Printf("Đã lưu dữ liệu vào %s", outputPath)
-	return nil
+// archiveOptions trả về tùy chọn crawler cho chế độ ghi hoặc phát lại lưu trữ HTTP
+func archiveOptions(mode, dir string, cfg *config.Config) ([]crawler.Option, error) {
+	client := utils.NewHTTPClient(cfg.ConnectTimeout.Duration, cfg.ResponseTimeout.Duration)
+
+	switch mode {
+	case "live":
+		return nil, nil
+	case "record":
+		recorder, err := archive.NewRecorder(dir, client.Transport)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = recorder
+		log.Printf("Chế độ ghi: mọi phản hồi HTTP được lưu vào %s", dir)
+		return []crawler.Option{crawler.WithHTTPClient(client)}, nil
+	case "replay":
+		replayer, err := archive.NewReplayer(dir)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = replayer
+		log.Printf("Chế độ phát lại: chỉ dùng phản hồi trong %s, không truy cập mạng", dir)
+		// Không cần giới hạn tốc độ (kể cả Crawl-delay đã ghi) khi không có máy chủ thật
+		return []crawler.Option{
+			crawler.WithHTTPClient(client),
+			crawler.WithRateLimit(0, 0),
+			crawler.WithIgnoreCrawlDelay(true),
+		}, nil
+	default:
+		return nil, fmt.Errorf("mode không hợp lệ: %q (live, record hoặc replay)", mode)
+	}
 }
 
 // printStats in thống kê về tài liệu đã tải
@@ -87,19 +142,15 @@ func printStats(docs map[string][]models.Document) {
 	log.Println("--------------------------------------------------")
 
 	for category, categoryDocs := range docs {
-		displayName, exists := models.CategoryFolderMapping[category]
-		if !exists {
-			displayName = category
-		}
-
-		log.Printf("- %s: %d tài liệu", displayName, len(categoryDocs))
+		log.Printf("- %s: %d tài liệu", models.CategoryFolder(category), len(categoryDocs))
 		totalDocs += len(categoryDocs)
 	}
 
 	log.Println("--------------------------------------------------")
 	log.Printf("Tổng cộng: %d tài liệu đã thu thập", totalDocs)
 	log.Println("Lưu ý: Số lượng tài liệu này đã loại bỏ các bản trùng lặp")
-	log.Println("Các tài liệu trùng lặp được xác định dựa trên tên, URL tải xuống, danh mục và đường dẫn tệp")
+	log.Println("Các tài liệu trùng lặp được xác định theo fileid của liên kết tải xuống (theo từng site)")
+	log.Println("Liên kết không hợp lệ sẽ dùng site, danh mục, tên và URL tải xuống")
 	log.Println("Hệ thống ưu tiên giữ lại tài liệu có đầy đủ thông tin nhất (ít trường rỗng nhất)")
 	log.Println("--------------------------------------------------")
 }
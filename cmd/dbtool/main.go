@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/netco-crawler/internal/config"
+	"github.com/netco-crawler/internal/database"
+)
+
+func main() {
+	importPath := flag.String("import", "", "Import a data.json file into the database (existing documents are updated)")
+	exportPath := flag.String("export", "", "Export all documents from the database to a data.json file")
+	cfg, err := config.NewLoader(flag.CommandLine).Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Lỗi cấu hình: %v", err)
+	}
+	if *importPath == "" && *exportPath == "" {
+		log.Fatal("Cần ít nhất một trong hai cờ -import hoặc -export")
+	}
+
+	// Mở trực tiếp, không tự nhập data_file như crawler và web server
+	ctx := context.Background()
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		log.Fatalf("Lỗi cơ sở dữ liệu: %v", err)
+	}
+	defer db.Close()
+
+	if *importPath != "" {
+		n, err := db.ImportJSON(ctx, *importPath)
+		if err != nil {
+			log.Fatalf("Lỗi khi nhập %s: %v", *importPath, err)
+		}
+		log.Printf("Đã nhập %d tài liệu từ %s vào %s", n, *importPath, cfg.Database)
+	}
+
+	if *exportPath != "" {
+		if err := db.ExportJSON(ctx, *exportPath); err != nil {
+			log.Fatalf("Lỗi khi xuất %s: %v", *exportPath, err)
+		}
+		count, err := db.CountDocuments(ctx)
+		if err != nil {
+			log.Fatalf("Lỗi cơ sở dữ liệu: %v", err)
+		}
+		log.Printf("Đã xuất %d tài liệu từ %s ra %s", count, cfg.Database, *exportPath)
+	}
+}
@@ -1,77 +1,127 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"text/template"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/netco-crawler/internal/config"
 	"github.com/netco-crawler/internal/crawler"
+	"github.com/netco-crawler/internal/database"
 	"github.com/netco-crawler/internal/models"
-)
-
-const (
-	port           = 8080
-	htmlDir        = "./respone"
-	documentsDir   = "./static/documents"
-	baseNetcoURL   = "https://www.netcovn.com.vn"
-	dataOutputFile = "./static/data.json"
+	"github.com/netco-crawler/internal/storage"
 )
 
 func main() {
 	// Parse command line flags
 	skipCrawl := flag.Bool("skip-crawl", false, "Skip crawling data and only start the web server")
-	flag.Parse()
-
-	// Kiểm tra thư mục HTML
-	if _, err := os.Stat(htmlDir); os.IsNotExist(err) {
-		log.Fatalf("Thư mục HTML không tồn tại: %s", htmlDir)
+	cfg, err := config.NewLoader(flag.CommandLine).Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Lỗi cấu hình: %v", err)
 	}
 
 	// Đảm bảo thư mục đích tồn tại
-	if err := os.MkdirAll(documentsDir, 0755); err != nil {
+	if err := os.MkdirAll(cfg.DocumentsDir, 0755); err != nil {
 		log.Fatalf("Không thể tạo thư mục đích: %v", err)
 	}
 
-	// Tạo crawler
-	c := crawler.NewCrawler(htmlDir, documentsDir, baseNetcoURL)
+	// Hủy thu thập và tắt server khi nhận SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Kho lưu tài liệu dùng chung cho crawler và phục vụ /documents
+	store, err := cfg.OpenStorage()
+	if err != nil {
+		log.Fatalf("Lỗi mở kho lưu trữ: %v", err)
+	}
+
+	// Cơ sở dữ liệu siêu dữ liệu, lần đầu chạy sẽ nhập data.json của phiên bản cũ
+	db, err := cfg.OpenDatabase(ctx)
+	if err != nil {
+		log.Fatalf("Lỗi cơ sở dữ liệu: %v", err)
+	}
+	defer db.Close()
 
 	// Nếu không skip crawl, thực hiện thu thập dữ liệu
 	if !*skipCrawl {
+		run, err := db.StartRun(ctx)
+		if err != nil {
+			log.Fatalf("Lỗi cơ sở dữ liệu: %v", err)
+		}
+		// Ghi nhận lần chạy thất bại trước khi thoát vì lỗi
+		fail := func(format string, err error) {
+			run.Finish(context.Background(), database.RunFailed, 0, err)
+			log.Fatalf(format, err)
+		}
+
+		// Tạo crawler
+		c, err := crawler.NewCrawlerFromConfig(cfg, crawler.WithStorage(store), crawler.WithDatabase(db, run))
+		if err != nil {
+			fail("Lỗi tạo crawler: %v", err)
+		}
+
 		// Xử lý tệp HTML
 		log.Println("Bắt đầu phân tích các tệp HTML...")
-		if err := c.ProcessHTMLFiles(); err != nil {
-			log.Fatalf("Lỗi khi xử lý tệp HTML: %v", err)
+		if err := c.ProcessHTMLFilesContext(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			fail("Lỗi khi xử lý tệp HTML: %v", err)
 		}
 
 		// Tải xuống tài liệu
-		log.Println("Bắt đầu tải xuống tài liệu...")
-		if err := c.DownloadDocuments(); err != nil {
-			log.Fatalf("Lỗi khi tải xuống tài liệu: %v", err)
+		if ctx.Err() == nil {
+			log.Println("Bắt đầu tải xuống tài liệu...")
+			if err := c.DownloadDocumentsContext(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				fail("Lỗi khi tải xuống tài liệu: %v", err)
+			}
+		}
+
+		c.Close()
+
+		// Lưu vào cơ sở dữ liệu và xuất data.json, kể cả khi bị hủy giữa chừng
+		if err := run.SaveAndExport(ctx, c.Result(ctx.Err()), cfg.DataFile); err != nil {
+			log.Fatalf("Lỗi khi lưu dữ liệu: %v", err)
 		}
 
-		// Xuất dữ liệu sang JSON
-		if err := saveDataToJSON(c.GetDocuments(), dataOutputFile); err != nil {
-			log.Fatalf("Lỗi khi lưu dữ liệu vào JSON: %v", err)
+		if ctx.Err() != nil {
+			log.Println("Đã dừng theo yêu cầu, dữ liệu thu thập được đã được lưu vào", cfg.Database)
+			return
 		}
 
+		if pageErrors := c.PageErrors(); len(pageErrors) > 0 {
+			log.Printf("Có %d trang danh sách không tải được, lần chạy được lưu ở trạng thái %s", len(pageErrors), database.RunPartial)
+		}
 		log.Println("Thu thập dữ liệu hoàn tất. Bắt đầu khởi động web server...")
 	} else {
 		log.Println("Bỏ qua thu thập dữ liệu, chỉ khởi động web server...")
 	}
 
+	// Tên hiển thị của danh mục phát hiện tự động trong dữ liệu đã lưu
+	registerCategories(ctx, db)
+
 	// Thiết lập web server
 	r := gin.Default()
 
-	// Phục vụ tệp tĩnh
-	r.Static("/documents", documentsDir)
-	r.Static("/assets", "./static")
+	// Phục vụ tài liệu từ kho lưu trữ và tệp tĩnh. Chỉ công khai css và js, không phục vụ
+	// cả thư mục static vì có thể chứa dữ liệu của crawler
+	r.GET("/documents/*path", serveDocument(store))
+	r.HEAD("/documents/*path", serveDocument(store))
+	r.Static("/assets/css", "./static/css")
+	r.Static("/assets/js", "./static/js")
 
 	// Thêm hàm trợ giúp cho template (phải đặt TRƯỚC khi load template)
 	r.SetFuncMap(template.FuncMap{
@@ -85,8 +135,8 @@ func main() {
 
 	// Phục vụ trang chủ - hiển thị tất cả các danh mục
 	r.GET("/", func(c *gin.Context) {
-		// Đọc dữ liệu từ tệp JSON
-		docs, categoryMap := loadDocumentsFromJSON(dataOutputFile)
+		// Đọc dữ liệu từ cơ sở dữ liệu
+		docs, categoryMap := loadDocuments(c.Request.Context(), db)
 
 		// Tính toán thêm thống kê
 		totalDocs := 0
@@ -108,8 +158,8 @@ func main() {
 	r.GET("/category/:name", func(c *gin.Context) {
 		categoryName := c.Param("name")
 
-		// Đọc dữ liệu từ tệp JSON
-		docs, categoryMap := loadDocumentsFromJSON(dataOutputFile)
+		// Đọc dữ liệu từ cơ sở dữ liệu
+		docs, categoryMap := loadDocuments(c.Request.Context(), db)
 
 		var categoryDocs []models.Document
 		var categoryTitle string
@@ -127,67 +177,284 @@ func main() {
 		})
 	})
 
-	// API point để lấy dữ liệu JSON
+	// API point để lấy dữ liệu JSON, hỗ trợ lọc và sắp xếp theo các trường đã phân tích
 	r.GET("/api/documents", func(c *gin.Context) {
-		// Đọc dữ liệu từ tệp JSON
-		docs, _ := loadDocumentsFromJSON(dataOutputFile)
-		c.JSON(http.StatusOK, docs)
+		// Đọc dữ liệu từ cơ sở dữ liệu
+		docs, _ := loadDocuments(c.Request.Context(), db)
+
+		filtered, err := filterDocuments(docs, c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, filtered)
 	})
 
-	log.Printf("Khởi động server tại http://localhost:%d", port)
-	r.Run(fmt.Sprintf(":%d", port))
-}
+	// API lấy một tài liệu theo ID (site:fileid)
+	r.GET("/api/documents/:id", func(c *gin.Context) {
+		doc, err := db.Document(c.Request.Context(), c.Param("id"))
+		if errors.Is(err, database.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "không tìm thấy tài liệu"})
+			return
+		}
+		if err != nil {
+			log.Printf("Lỗi khi đọc tài liệu %s: %v", c.Param("id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "lỗi cơ sở dữ liệu"})
+			return
+		}
+		c.JSON(http.StatusOK, doc)
+	})
 
-// saveDataToJSON lưu dữ liệu vào tệp JSON
-func saveDataToJSON(docs map[string][]models.Document, outputPath string) error {
-	// Đảm bảo thư mục đích tồn tại
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return fmt.Errorf("không thể tạo thư mục đích: %w", err)
+	// API lịch sử của một tài liệu: các phiên bản tệp, siêu dữ liệu qua từng lần thu thập và các lần tải
+	r.GET("/api/documents/:id/history", func(c *gin.Context) {
+		history, err := documentHistory(c.Request.Context(), db, c.Param("id"))
+		if errors.Is(err, database.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "không tìm thấy tài liệu"})
+			return
+		}
+		if err != nil {
+			log.Printf("Lỗi khi đọc lịch sử tài liệu %s: %v", c.Param("id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "lỗi cơ sở dữ liệu"})
+			return
+		}
+		c.JSON(http.StatusOK, history)
+	})
+
+	srv := &http.Server{
+		Addr:    cfg.ListenAddr,
+		Handler: r,
 	}
 
-	// Tạo tệp đích
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("không thể tạo tệp JSON: %w", err)
+	// Tắt server nhẹ nhàng khi nhận tín hiệu dừng
+	go func() {
+		<-ctx.Done()
+		log.Println("Đang tắt server...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Lỗi khi tắt server: %v", err)
+		}
+	}()
+
+	log.Printf("Khởi động server tại %s", cfg.ListenAddr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Lỗi khi chạy server: %v", err)
+	}
+}
+
+// serveDocument trả về handler phục vụ tài liệu từ kho lưu trữ theo FilePath.
+// Kho cục bộ hỗ trợ Range và If-Modified-Since qua http.ServeContent
+func serveDocument(store storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("path"), "/")
+		body, info, err := store.Get(c.Request.Context(), key)
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotExist) {
+				log.Printf("Lỗi khi đọc tài liệu %s: %v", key, err)
+			}
+			c.Status(http.StatusNotFound)
+			return
+		}
+		defer body.Close()
+
+		if info.ContentType != "" {
+			c.Header("Content-Type", info.ContentType)
+		}
+		if seeker, ok := body.(io.ReadSeeker); ok {
+			http.ServeContent(c.Writer, c.Request, path.Base(key), info.ModTime, seeker)
+			return
+		}
+
+		if info.ContentType == "" {
+			if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+				c.Header("Content-Type", contentType)
+			}
+		}
+		c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+		if !info.ModTime.IsZero() {
+			c.Header("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+		}
+		c.Status(http.StatusOK)
+		if c.Request.Method != http.MethodHead {
+			io.Copy(c.Writer, body)
+		}
 	}
-	defer file.Close()
+}
+
+// history là lịch sử của một tài liệu trả về qua /api/documents/:id/history
+type history struct {
+	ID           string                 `json:"id"`
+	Document     *models.Document       `json:"document"` // nil nếu tài liệu không còn trên trang danh sách
+	Versions     []database.Version     `json:"versions"`
+	Observations []database.Observation `json:"observations"`
+	Attempts     []database.Attempt     `json:"attempts"`
+}
 
-	// Encode dữ liệu thành JSON
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(docs); err != nil {
-		return fmt.Errorf("không thể encode dữ liệu thành JSON: %w", err)
+// documentHistory đọc lịch sử của tài liệu id, lỗi database.ErrNotFound nếu chưa từng thấy tài liệu
+func documentHistory(ctx context.Context, db *database.DB, id string) (*history, error) {
+	doc, err := db.Document(ctx, id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return nil, err
 	}
 
-	log.Printf("Đã lưu dữ liệu vào %s", outputPath)
-	return nil
+	h := &history{ID: id, Document: doc}
+	if h.Versions, err = db.Versions(ctx, id); err != nil {
+		return nil, err
+	}
+	if h.Observations, err = db.Observations(ctx, id); err != nil {
+		return nil, err
+	}
+	if h.Attempts, err = db.Attempts(ctx, id); err != nil {
+		return nil, err
+	}
+	if doc == nil && len(h.Versions) == 0 && len(h.Observations) == 0 {
+		return nil, fmt.Errorf("%s: %w", id, database.ErrNotFound)
+	}
+	return h, nil
 }
 
-// loadDocumentsFromJSON tải dữ liệu từ tệp JSON
-func loadDocumentsFromJSON(inputPath string) (map[string][]models.Document, map[string]string) {
-	file, err := os.Open(inputPath)
+// filterDocuments lọc và sắp xếp tài liệu theo tham số truy vấn:
+// category, modified_after, modified_before (YYYY-MM-DD hoặc RFC3339), min_size, max_size (byte),
+// min_downloads, sort (name|size|downloads|modified) và order (asc|desc)
+func filterDocuments(docs map[string][]models.Document, query url.Values) (map[string][]models.Document, error) {
+	parseTime := func(key string) (*time.Time, error) {
+		value := query.Get(key)
+		if value == "" {
+			return nil, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", value, models.VietnamLocation); err == nil {
+			return &t, nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s không hợp lệ: %q", key, value)
+		}
+		return &t, nil
+	}
+	parseInt := func(key string) (int64, bool, error) {
+		value := query.Get(key)
+		if value == "" {
+			return 0, false, nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("%s không hợp lệ: %q", key, value)
+		}
+		return n, true, nil
+	}
+
+	after, err := parseTime("modified_after")
 	if err != nil {
-		log.Printf("Không thể mở tệp JSON: %v", err)
-		return make(map[string][]models.Document), make(map[string]string)
+		return nil, err
+	}
+	before, err := parseTime("modified_before")
+	if err != nil {
+		return nil, err
 	}
-	defer file.Close()
+	minSize, hasMinSize, err := parseInt("min_size")
+	if err != nil {
+		return nil, err
+	}
+	maxSize, hasMaxSize, err := parseInt("max_size")
+	if err != nil {
+		return nil, err
+	}
+	minDownloads, hasMinDownloads, err := parseInt("min_downloads")
+	if err != nil {
+		return nil, err
+	}
+
+	var less func(a, b models.Document) bool
+	switch query.Get("sort") {
+	case "":
+	case "name":
+		less = func(a, b models.Document) bool { return a.Name < b.Name }
+	case "size":
+		less = func(a, b models.Document) bool { return a.SizeBytes < b.SizeBytes }
+	case "downloads":
+		less = func(a, b models.Document) bool { return a.DownloadCount < b.DownloadCount }
+	case "modified":
+		less = func(a, b models.Document) bool {
+			if a.ModifiedAt == nil || b.ModifiedAt == nil {
+				return a.ModifiedAt == nil && b.ModifiedAt != nil
+			}
+			return a.ModifiedAt.Before(*b.ModifiedAt)
+		}
+	default:
+		return nil, fmt.Errorf("sort không hợp lệ: %q", query.Get("sort"))
+	}
+	desc := query.Get("order") == "desc"
+
+	category := query.Get("category")
+	result := make(map[string][]models.Document)
+	for cat, categoryDocs := range docs {
+		if category != "" && cat != category {
+			continue
+		}
+
+		var kept []models.Document
+		for _, doc := range categoryDocs {
+			if after != nil && (doc.ModifiedAt == nil || doc.ModifiedAt.Before(*after)) {
+				continue
+			}
+			if before != nil && (doc.ModifiedAt == nil || !doc.ModifiedAt.Before(*before)) {
+				continue
+			}
+			if hasMinSize && doc.SizeBytes < minSize {
+				continue
+			}
+			if hasMaxSize && doc.SizeBytes > maxSize {
+				continue
+			}
+			if hasMinDownloads && int64(doc.DownloadCount) < minDownloads {
+				continue
+			}
+			kept = append(kept, doc)
+		}
+
+		if less != nil {
+			sort.SliceStable(kept, func(i, j int) bool {
+				if desc {
+					return less(kept[j], kept[i])
+				}
+				return less(kept[i], kept[j])
+			})
+		}
+
+		result[cat] = kept
+	}
+
+	return result, nil
+}
 
-	var docs map[string][]models.Document
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&docs); err != nil {
-		log.Printf("Không thể decode JSON: %v", err)
+// loadDocuments đọc tài liệu từ cơ sở dữ liệu và danh sách danh mục cho frontend
+func loadDocuments(ctx context.Context, db *database.DB) (map[string][]models.Document, map[string]string) {
+	docs, err := db.Documents(ctx)
+	if err != nil {
+		log.Printf("Không thể đọc tài liệu từ cơ sở dữ liệu: %v", err)
 		return make(map[string][]models.Document), make(map[string]string)
 	}
 
-	// Sử dụng CategoryFolderMapping để tạo danh sách danh mục cho frontend
+	// Tạo danh sách danh mục cho frontend, danh mục không có ánh xạ dùng key như là display name
 	categoryMap := make(map[string]string)
 	for cat := range docs {
-		if displayName, ok := models.CategoryFolderMapping[cat]; ok {
-			categoryMap[cat] = displayName
-		} else {
-			categoryMap[cat] = cat // Sử dụng key như là display name nếu không tìm thấy
-		}
+		categoryMap[cat] = models.CategoryFolder(cat)
 	}
 
 	return docs, categoryMap
 }
+
+// registerCategories đăng ký tên hiển thị của các danh mục phát hiện tự động đã lưu trong cơ sở dữ liệu.
+// Chỉ gọi một lần khi khởi động vì RegisterCategory giữ khóa ghi của bảng ánh xạ dùng chung
+func registerCategories(ctx context.Context, db *database.DB) {
+	docs, err := db.Documents(ctx)
+	if err != nil {
+		log.Printf("Không thể đọc danh mục từ cơ sở dữ liệu: %v", err)
+		return
+	}
+	for cat, categoryDocs := range docs {
+		if len(categoryDocs) > 0 {
+			models.RegisterCategory(cat, categoryDocs[0].CategoryName)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/netco-crawler/internal/config"
+	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/storage"
+	"github.com/netco-crawler/internal/utils"
+)
+
+// problem là một sai lệch giữa cơ sở dữ liệu và kho lưu trữ tài liệu
+type problem struct {
+	Kind   string // failed, missing, corrupt hoặc orphaned
+	Path   string
+	Detail string
+}
+
+func main() {
+	// Dùng chung cấu hình với crawler để biết cơ sở dữ liệu và thư mục tài liệu
+	cfg, err := config.NewLoader(flag.CommandLine).Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Lỗi cấu hình: %v", err)
+	}
+
+	// Tệp được đọc qua kho lưu trữ đã cấu hình (thư mục tài liệu hoặc S3)
+	store, err := cfg.OpenStorage()
+	if err != nil {
+		log.Fatalf("Lỗi mở kho lưu trữ: %v", err)
+	}
+
+	db, err := cfg.OpenDatabase(context.Background())
+	if err != nil {
+		log.Fatalf("Lỗi cơ sở dữ liệu: %v", err)
+	}
+	docs, err := db.Documents(context.Background())
+	if err != nil {
+		log.Fatalf("Lỗi khi đọc dữ liệu: %v", err)
+	}
+	versions, err := db.VersionFiles(context.Background())
+	db.Close()
+	if err != nil {
+		log.Fatalf("Lỗi khi đọc dữ liệu: %v", err)
+	}
+	// Tệp của các phiên bản cũ được kiểm tra như tài liệu
+	for _, v := range versions {
+		docs[models.VersionsDir] = append(docs[models.VersionsDir], models.Document{
+			Name:     fmt.Sprintf("%s phiên bản %d", v.DocumentID, v.Version),
+			FilePath: filepath.FromSlash(v.FilePath),
+			SHA256:   v.SHA256,
+			FileSize: v.FileSize,
+		})
+	}
+
+	problems, checked, err := verify(context.Background(), docs, store)
+	if err != nil {
+		log.Fatalf("Lỗi khi kiểm tra kho lưu trữ %s: %v", cfg.Storage, err)
+	}
+
+	counts := make(map[string]int)
+	for _, p := range problems {
+		counts[p.Kind]++
+		fmt.Printf("%-8s  %s  %s\n", p.Kind, p.Path, p.Detail)
+	}
+
+	log.Printf("Đã kiểm tra %d tệp: %d tải thất bại, %d thiếu, %d hỏng, %d không thuộc tài liệu nào",
+		checked, counts["failed"], counts["missing"], counts["corrupt"], counts["orphaned"])
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// verify đọc lại từng tệp được tham chiếu trong docs từ kho lưu trữ store, băm và so với mã băm,
+// kích thước đã lưu (hoặc kích thước trong danh sách nếu tài liệu chưa có mã băm), sau đó tìm
+// các đối tượng trong kho không thuộc tài liệu nào
+func verify(ctx context.Context, docs map[string][]models.Document, store storage.Storage) ([]problem, int, error) {
+	var problems []problem
+	expected := make(map[string]bool)
+	checked := 0
+
+	categories := make([]string, 0, len(docs))
+	for category := range docs {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		for _, doc := range docs[category] {
+			if doc.FilePath == "" {
+				continue
+			}
+			key, err := storage.CleanKey(doc.FilePath)
+			if err != nil {
+				problems = append(problems, problem{"corrupt", doc.FilePath, err.Error()})
+				continue
+			}
+			if expected[key] {
+				continue
+			}
+			expected[key] = true
+			checked++
+
+			sum, size, err := hashObject(ctx, store, key)
+			if errors.Is(err, storage.ErrNotExist) {
+				// FilePath được xác định trước khi tải nên tài liệu tải lỗi chưa từng có tệp
+				if doc.DownloadError != "" {
+					problems = append(problems, problem{"failed", doc.FilePath, fmt.Sprintf("%s: %s", doc.Name, doc.DownloadError)})
+				} else {
+					problems = append(problems, problem{"missing", doc.FilePath, doc.Name})
+				}
+				continue
+			}
+			if err != nil {
+				problems = append(problems, problem{"corrupt", doc.FilePath, err.Error()})
+				continue
+			}
+
+			switch {
+			case doc.SHA256 != "" && sum != doc.SHA256:
+				problems = append(problems, problem{"corrupt", doc.FilePath, fmt.Sprintf("sha256 %s, mong đợi %s", sum, doc.SHA256)})
+			case doc.FileSize > 0 && size != doc.FileSize:
+				problems = append(problems, problem{"corrupt", doc.FilePath, fmt.Sprintf("%d byte, mong đợi %d", size, doc.FileSize)})
+			case doc.SHA256 == "" && !doc.SizeMatches(size):
+				problems = append(problems, problem{"corrupt", doc.FilePath, fmt.Sprintf("%d byte, danh sách ghi %s KB", size, doc.Size)})
+			default:
+				if reason, _ := detectErrorPage(ctx, store, key); reason != "" {
+					problems = append(problems, problem{"corrupt", doc.FilePath, reason})
+				}
+			}
+		}
+	}
+
+	// List của kho cục bộ đã bỏ qua tệp tạm (.tmp, .tmp.meta) của lần tải dở dang
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return problems, checked, err
+	}
+	for _, object := range objects {
+		if !expected[object.Key] {
+			problems = append(problems, problem{"orphaned", filepath.FromSlash(object.Key), ""})
+		}
+	}
+
+	return problems, checked, nil
+}
+
+// hashObject băm nội dung đối tượng key trong kho
+func hashObject(ctx context.Context, store storage.Storage, key string) (string, int64, error) {
+	body, _, err := store.Get(ctx, key)
+	if err != nil {
+		return "", 0, err
+	}
+	defer body.Close()
+
+	return utils.HashReader(body)
+}
+
+// detectErrorPage kiểm tra đối tượng key có phải trang lỗi thay cho tệp hay không, chỉ đọc phần đầu
+func detectErrorPage(ctx context.Context, store storage.Storage, key string) (string, error) {
+	body, info, err := store.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	return utils.DetectErrorPageReader(body, info.ContentType, key)
+}
@@ -0,0 +1,86 @@
+// Package archive ghi lại toàn bộ yêu cầu HTTP của crawler (trang danh sách và tệp tải xuống)
+// vào một thư mục lưu trữ và phát lại chúng mà không cần mạng.
+//
+// Cấu trúc thư mục lưu trữ:
+//
+//	index.jsonl        mỗi dòng là một Entry theo thứ tự yêu cầu
+//	bodies/<sha256>    nội dung phản hồi, đặt tên theo SHA-256 nên nội dung trùng chỉ lưu một lần
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Tên tệp và thư mục bên trong thư mục lưu trữ
+const (
+	indexFile = "index.jsonl"
+	bodiesDir = "bodies"
+)
+
+// Entry là một cặp yêu cầu/phản hồi đã ghi
+type Entry struct {
+	Time       time.Time   `json:"time"`
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Range      string      `json:"range,omitempty"`    // header Range của yêu cầu (tải tiếp)
+	IfRange    string      `json:"if_range,omitempty"` // header If-Range của yêu cầu
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"` // SHA-256 của nội dung, là tên tệp trong bodies/
+	Size       int64       `json:"size"`
+}
+
+// key trả về khóa tra cứu của một yêu cầu. Range và If-Range thuộc về khóa để phản hồi 206 của
+// một lần tải tiếp không bị phát lại cho yêu cầu tải toàn bộ tệp và ngược lại
+func key(method, url, rng, ifRange string) string {
+	k := method + " " + url
+	if rng != "" || ifRange != "" {
+		k += " range=" + rng + " if-range=" + ifRange
+	}
+	return k
+}
+
+// requestKey trả về khóa tra cứu của req
+func requestKey(req *http.Request) string {
+	return key(req.Method, req.URL.String(), req.Header.Get("Range"), req.Header.Get("If-Range"))
+}
+
+// bodyPath trả về đường dẫn tệp nội dung theo mã băm
+func bodyPath(dir, sum string) string {
+	return filepath.Join(dir, bodiesDir, sum)
+}
+
+// ensureDir tạo thư mục lưu trữ và thư mục con bodies/
+func ensureDir(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, bodiesDir), 0755); err != nil {
+		return fmt.Errorf("không thể tạo thư mục lưu trữ %s: %w", dir, err)
+	}
+	return nil
+}
+
+// readIndex đọc toàn bộ bản ghi của thư mục lưu trữ
+func readIndex(dir string) ([]Entry, error) {
+	file, err := os.Open(filepath.Join(dir, indexFile))
+	if err != nil {
+		return nil, fmt.Errorf("không thể mở chỉ mục lưu trữ: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var entry Entry
+		if err := decoder.Decode(&entry); err != nil {
+			return entries, fmt.Errorf("chỉ mục lưu trữ bị hỏng sau %d bản ghi: %w", len(entries), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
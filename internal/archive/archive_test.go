@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayMatchesRangeRequests(t *testing.T) {
+	const content = "%PDF-1.4 0123456789abcdefghijklmnopqrstuvwxyz"
+	modified := time.Date(2025, time.January, 17, 8, 8, 11, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "report.pdf", modified, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	url := srv.URL + "/SharedFiles/Download.aspx?fileid=418"
+	rangeHeaders := http.Header{"Range": {"bytes=10-"}, "If-Range": {`"v1"`}}
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ghi yêu cầu tải tiếp trước để bản ghi 206 đứng trước bản ghi 200 của cùng URL
+	if status, _ := get(t, recorder, url, rangeHeaders); status != http.StatusPartialContent {
+		t.Fatalf("recorded range request got %d, want 206", status)
+	}
+	if status, _ := get(t, recorder, url, nil); status != http.StatusOK {
+		t.Fatalf("recorded full request got %d, want 200", status)
+	}
+
+	replayer, err := NewReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		header http.Header
+		status int
+		body   string
+	}{
+		{"full", nil, http.StatusOK, content},
+		{"range", rangeHeaders, http.StatusPartialContent, content[10:]},
+		{"other range", http.Header{"Range": {"bytes=20-"}, "If-Range": {`"v1"`}}, http.StatusNotFound, ""},
+		{"full again", nil, http.StatusOK, content},
+	}
+	for _, tt := range tests {
+		status, body := get(t, replayer, url, tt.header)
+		if status != tt.status || body != tt.body {
+			t.Errorf("%s: replayed %d %q, want %d %q", tt.name, status, body, tt.status, tt.body)
+		}
+	}
+}
+
+// get gửi yêu cầu GET qua transport rt và trả về mã trạng thái cùng nội dung
+func get(t *testing.T, rt http.RoundTripper, url string, header http.Header) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
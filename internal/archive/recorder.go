@@ -0,0 +1,156 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Recorder là http.RoundTripper chuyển yêu cầu tới transport thật và ghi lại phản hồi.
+// Phản hồi chỉ được ghi khi body đã được đọc hết hoặc đóng lại, nên yêu cầu bị hủy giữa chừng sẽ bị bỏ qua
+type Recorder struct {
+	dir  string
+	next http.RoundTripper
+	mu   sync.Mutex
+}
+
+// NewRecorder tạo Recorder ghi vào thư mục dir, next nil sẽ dùng http.DefaultTransport.
+// Bản ghi mới được nối vào chỉ mục hiện có
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if err := ensureDir(dir); err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, next: next}, nil
+}
+
+// RoundTrip thực hiện yêu cầu và bọc body để ghi lại nội dung khi đọc
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(r.dir, bodiesDir), ".body-*")
+	if err != nil {
+		log.Printf("Không thể ghi lưu trữ cho %s: %v", req.URL, err)
+		return resp, nil
+	}
+
+	hasher := sha256.New()
+	resp.Body = &recordingBody{
+		body:     resp.Body,
+		tmp:      tmp,
+		hasher:   hasher,
+		writer:   io.MultiWriter(tmp, hasher),
+		recorder: r,
+		entry: Entry{
+			Time:       time.Now(),
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			Range:      req.Header.Get("Range"),
+			IfRange:    req.Header.Get("If-Range"),
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header.Clone(),
+		},
+	}
+	return resp, nil
+}
+
+// save hoàn tất một bản ghi: chuyển nội dung vào bodies/ và nối vào chỉ mục
+func (r *Recorder) save(entry Entry, tmpPath string) error {
+	target := bodyPath(r.dir, entry.Body)
+	if _, err := os.Stat(target); err == nil {
+		os.Remove(tmpPath) // Nội dung đã có trong lưu trữ
+	} else if err := os.Rename(tmpPath, target); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(r.dir, indexFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// recordingBody sao chép nội dung đọc được vào tệp tạm và tính SHA-256
+type recordingBody struct {
+	body     io.ReadCloser
+	tmp      *os.File
+	hasher   hash.Hash
+	writer   io.Writer
+	recorder *Recorder
+	entry    Entry
+	done     bool // đã đọc tới EOF
+	failed   bool // đọc hoặc ghi lỗi, bản ghi sẽ bị bỏ
+	once     sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 && !b.failed {
+		if _, werr := b.writer.Write(p[:n]); werr != nil {
+			b.failed = true
+		}
+		b.entry.Size += int64(n)
+	}
+	switch {
+	case errors.Is(err, io.EOF):
+		b.done = true
+	case err != nil:
+		b.failed = true
+	}
+	return n, err
+}
+
+// Close đọc nốt phần còn lại (ví dụ trang lỗi được đóng ngay) rồi lưu bản ghi
+func (b *recordingBody) Close() error {
+	if !b.done && !b.failed {
+		if _, err := io.Copy(io.Discard, b); err != nil {
+			b.failed = true
+		}
+	}
+	err := b.body.Close()
+
+	b.once.Do(func() {
+		tmpPath := b.tmp.Name()
+		b.tmp.Close()
+		if b.failed || !b.done {
+			os.Remove(tmpPath)
+			return
+		}
+
+		b.entry.Body = hex.EncodeToString(b.hasher.Sum(nil))
+		if serr := b.recorder.save(b.entry, tmpPath); serr != nil {
+			os.Remove(tmpPath)
+			log.Printf("Không thể ghi lưu trữ cho %s: %v", b.entry.URL, serr)
+		}
+	})
+
+	return err
+}
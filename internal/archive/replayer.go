@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Replayer là http.RoundTripper trả lời yêu cầu từ thư mục lưu trữ, không bao giờ truy cập mạng.
+// Yêu cầu được tra theo phương thức, URL và header Range/If-Range. Các phản hồi của cùng một yêu cầu
+// được phát lại theo đúng thứ tự đã ghi (kể cả lỗi 503 trước lần thử lại), hết bản ghi thì lặp lại
+// phản hồi cuối. Yêu cầu không có trong lưu trữ nhận 404
+type Replayer struct {
+	dir     string
+	mu      sync.Mutex
+	entries map[string][]Entry
+	next    map[string]int
+}
+
+// NewReplayer nạp chỉ mục của thư mục lưu trữ dir
+func NewReplayer(dir string) (*Replayer, error) {
+	list, err := readIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replayer{
+		dir:     dir,
+		entries: make(map[string][]Entry),
+		next:    make(map[string]int),
+	}
+	for _, entry := range list {
+		k := key(entry.Method, entry.URL, entry.Range, entry.IfRange)
+		r.entries[k] = append(r.entries[k], entry)
+	}
+
+	log.Printf("Đã nạp %d phản hồi (%d yêu cầu) từ lưu trữ %s", len(list), len(r.entries), dir)
+	return r, nil
+}
+
+// RoundTrip trả về phản hồi đã ghi cho yêu cầu
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	k := requestKey(req)
+	r.mu.Lock()
+	recorded := r.entries[k]
+	i := r.next[k]
+	if i < len(recorded)-1 {
+		r.next[k] = i + 1
+	}
+	r.mu.Unlock()
+
+	if len(recorded) == 0 {
+		log.Printf("Không có trong lưu trữ: %s %s", req.Method, req.URL)
+		return &http.Response{
+			Status:        "404 Not Found",
+			StatusCode:    http.StatusNotFound,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        make(http.Header),
+			Body:          http.NoBody,
+			ContentLength: 0,
+			Request:       req,
+		}, nil
+	}
+
+	entry := recorded[i]
+	body, err := os.Open(bodyPath(r.dir, entry.Body))
+	if err != nil {
+		return nil, fmt.Errorf("lưu trữ thiếu nội dung của %s: %w", entry.URL, err)
+	}
+
+	header := entry.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+
+	return &http.Response{
+		Status:        entry.Status,
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: entry.Size,
+		Request:       req,
+	}, nil
+}
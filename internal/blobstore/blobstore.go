@@ -0,0 +1,190 @@
+// Package blobstore lưu tệp tài liệu theo nội dung (SHA-256) để các bản sao giống hệt nhau
+// trong nhiều danh mục hoặc nhiều bản lưu trữ chỉ chiếm dung lượng một lần.
+//
+// Cấu trúc thư mục:
+//
+//	<dir>/ab/cd/abcdef...   nội dung tệp, tên là SHA-256 (hex), chỉ đọc
+//
+// Cây thư mục dễ đọc theo danh mục (ví dụ "Báo cáo tài chính/...") được dựng bằng hard link
+// hoặc symlink trỏ vào kho nên vẫn phục vụ được qua r.Static("/documents", ...).
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// LinkMode là cách tạo tệp trong cây thư mục danh mục
+type LinkMode string
+
+const (
+	// HardLink dùng hard link, kho và cây danh mục phải cùng hệ thống tệp
+	HardLink LinkMode = "hardlink"
+	// SymLink dùng symlink tương đối trỏ vào kho
+	SymLink LinkMode = "symlink"
+)
+
+// Store là kho tệp theo nội dung
+type Store struct {
+	dir  string
+	mode LinkMode
+}
+
+// New tạo kho tại dir. mode rỗng dùng HardLink
+func New(dir string, mode LinkMode) (*Store, error) {
+	switch mode {
+	case "":
+		mode = HardLink
+	case HardLink, SymLink:
+	default:
+		return nil, fmt.Errorf("link_mode không hợp lệ: %q (hardlink hoặc symlink)", mode)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("không thể tạo thư mục kho: %w", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{dir: abs, mode: mode}, nil
+}
+
+// Path trả về đường dẫn của nội dung có mã băm sum trong kho
+func (s *Store) Path(sum string) string {
+	if len(sum) < 4 {
+		return filepath.Join(s.dir, sum)
+	}
+	return filepath.Join(s.dir, sum[:2], sum[2:4], sum)
+}
+
+// Has cho biết kho đã có nội dung có mã băm sum hay chưa
+func (s *Store) Has(sum string) bool {
+	_, err := os.Stat(s.Path(sum))
+	return err == nil
+}
+
+// Adopt đưa tệp path (đã biết SHA-256 là sum) vào kho và thay tệp đó bằng liên kết tới kho.
+// Nếu kho đã có nội dung giống hệt, tệp path bị thay bằng liên kết và không tốn thêm dung lượng.
+// Gọi lại với tệp đã là liên kết tới kho không làm gì
+func (s *Store) Adopt(path, sum string) error {
+	if sum == "" {
+		return errors.New("thiếu mã băm SHA-256")
+	}
+	blob := s.Path(sum)
+
+	if s.linked(path, blob) {
+		return nil
+	}
+
+	if !s.Has(sum) {
+		if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+			return fmt.Errorf("không thể tạo thư mục kho: %w", err)
+		}
+		if err := moveFile(path, blob); err != nil {
+			return fmt.Errorf("không thể đưa %s vào kho: %w", path, err)
+		}
+		// Nội dung trong kho dùng chung cho nhiều liên kết nên không được sửa
+		os.Chmod(blob, 0444)
+	}
+
+	return s.Link(sum, path)
+}
+
+// Link tạo (hoặc thay thế) tệp path là liên kết tới nội dung sum trong kho
+func (s *Store) Link(sum, path string) error {
+	blob := s.Path(sum)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Tạo liên kết tạm rồi đổi tên để thay tệp cũ một cách nguyên tử
+	tmp := path + ".link"
+	os.Remove(tmp)
+
+	mode := s.mode
+	if mode == HardLink {
+		if err := os.Link(blob, tmp); err != nil {
+			// Khác hệ thống tệp hoặc hệ thống tệp không hỗ trợ hard link
+			log.Printf("Không thể tạo hard link cho %s: %v, dùng symlink", path, err)
+			mode = SymLink
+		}
+	}
+	if mode == SymLink {
+		target, err := filepath.Rel(filepath.Dir(absPath(path)), blob)
+		if err != nil {
+			target = blob
+		}
+		if err := os.Symlink(target, tmp); err != nil {
+			return fmt.Errorf("không thể tạo liên kết %s: %w", path, err)
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("không thể tạo liên kết %s: %w", path, err)
+	}
+	return nil
+}
+
+// linked cho biết path đã là hard link hoặc symlink tới blob hay chưa
+func (s *Store) linked(path, blob string) bool {
+	blobInfo, err := os.Stat(blob)
+	if err != nil {
+		return false
+	}
+	// os.Stat đi theo symlink nên so sánh được cả hai loại liên kết
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(info, blobInfo)
+}
+
+// moveFile đổi tên src thành dst, sao chép rồi xóa nếu hai đường dẫn khác hệ thống tệp
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
+
+// absPath trả về đường dẫn tuyệt đối, giữ nguyên path nếu không xác định được
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
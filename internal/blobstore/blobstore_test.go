@@ -0,0 +1,166 @@
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile tạo tệp path với nội dung data và trả về mã băm SHA-256 của nội dung
+func writeFile(t *testing.T, path, data string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAdoptAddressesByContent(t *testing.T) {
+	for _, mode := range []LinkMode{HardLink, SymLink} {
+		t.Run(string(mode), func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := New(filepath.Join(dir, "blobs"), mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(dir, "docs", "Báo cáo tài chính", "BCTC 2024.pdf")
+			sum := writeFile(t, path, "%PDF-1.4 BCTC 2024")
+
+			if store.Has(sum) {
+				t.Fatal("Has() = true before Adopt")
+			}
+			if err := store.Adopt(path, sum); err != nil {
+				t.Fatalf("Adopt: %v", err)
+			}
+
+			want := filepath.Join(dir, "blobs", sum[:2], sum[2:4], sum)
+			if got := store.Path(sum); got != want {
+				t.Errorf("Path() = %s, want %s", got, want)
+			}
+			if !store.Has(sum) {
+				t.Error("Has() = false after Adopt")
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil || string(data) != "%PDF-1.4 BCTC 2024" {
+				t.Errorf("linked file = %q, %v", data, err)
+			}
+			if !store.linked(path, want) {
+				t.Error("adopted file is not linked to the blob")
+			}
+
+			info, err := os.Lstat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isLink := info.Mode()&os.ModeSymlink != 0; isLink != (mode == SymLink) {
+				t.Errorf("symlink = %v, want %v", isLink, mode == SymLink)
+			}
+
+			// Gọi lại với tệp đã là liên kết không làm gì
+			if err := store.Adopt(path, sum); err != nil {
+				t.Errorf("second Adopt: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdoptDeduplicates(t *testing.T) {
+	dir := t.TempDir()
+	store, err := New(filepath.Join(dir, "blobs"), HardLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := filepath.Join(dir, "docs", "Báo cáo tài chính", "BCTC 2024.pdf")
+	second := filepath.Join(dir, "docs", "Công bố thông tin", "BCTC 2024.pdf")
+	other := filepath.Join(dir, "docs", "Công bố thông tin", "Nghị quyết.pdf")
+	sum := writeFile(t, first, "%PDF-1.4 BCTC 2024")
+	if got := writeFile(t, second, "%PDF-1.4 BCTC 2024"); got != sum {
+		t.Fatal("identical content hashed differently")
+	}
+	otherSum := writeFile(t, other, "%PDF-1.4 Nghị quyết")
+
+	for path, sum := range map[string]string{first: sum, second: sum, other: otherSum} {
+		if err := store.Adopt(path, sum); err != nil {
+			t.Fatalf("Adopt %s: %v", path, err)
+		}
+	}
+
+	// Hai bản sao giống hệt nhau dùng chung một nội dung trong kho
+	a, err := os.Stat(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(a, b) {
+		t.Error("identical files are not deduplicated")
+	}
+	if store.linked(other, store.Path(sum)) {
+		t.Error("different content linked to the same blob")
+	}
+
+	var blobs int
+	filepath.Walk(filepath.Join(dir, "blobs"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			blobs++
+		}
+		return err
+	})
+	if blobs != 2 {
+		t.Errorf("store holds %d blobs, want 2", blobs)
+	}
+
+	// Nội dung trong kho dùng chung nên chỉ đọc
+	info, err := os.Stat(store.Path(sum))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0222 != 0 {
+		t.Errorf("blob mode = %v, want read-only", info.Mode().Perm())
+	}
+}
+
+func TestLinkReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	store, err := New(filepath.Join(dir, "blobs"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(dir, "a.pdf")
+	sum := writeFile(t, src, "nội dung mới")
+	if err := store.Adopt(src, sum); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tệp cũ cùng tên bị thay bằng liên kết tới nội dung trong kho
+	dst := filepath.Join(dir, "docs", "b.pdf")
+	writeFile(t, dst, "nội dung cũ")
+	if err := store.Link(sum, dst); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil || string(data) != "nội dung mới" {
+		t.Errorf("linked file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(dst + ".link"); !os.IsNotExist(err) {
+		t.Errorf("temporary link left behind: %v", err)
+	}
+}
+
+func TestNewRejectsUnknownMode(t *testing.T) {
+	if _, err := New(t.TempDir(), "copy"); err == nil {
+		t.Error("New accepted link mode \"copy\"")
+	}
+}
@@ -0,0 +1,470 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/utils"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config chứa cấu hình dùng chung cho crawler và web server
+type Config struct {
+	BaseURL         string   `json:"base_url" yaml:"base_url" toml:"base_url"`
+	HTMLDir         string   `json:"html_dir" yaml:"html_dir" toml:"html_dir"`
+	DocumentsDir    string   `json:"documents_dir" yaml:"documents_dir" toml:"documents_dir"`
+	DataFile        string   `json:"data_file" yaml:"data_file" toml:"data_file"`
+	Database        string   `json:"database" yaml:"database" toml:"database"`
+	QuarantineDir   string   `json:"quarantine_dir" yaml:"quarantine_dir" toml:"quarantine_dir"`
+	BlobDir         string   `json:"blob_dir" yaml:"blob_dir" toml:"blob_dir"`
+	LinkMode        string   `json:"link_mode" yaml:"link_mode" toml:"link_mode"`
+	Storage         string   `json:"storage" yaml:"storage" toml:"storage"`
+	S3Endpoint      string   `json:"s3_endpoint" yaml:"s3_endpoint" toml:"s3_endpoint"`
+	S3Region        string   `json:"s3_region" yaml:"s3_region" toml:"s3_region"`
+	S3Bucket        string   `json:"s3_bucket" yaml:"s3_bucket" toml:"s3_bucket"`
+	S3Prefix        string   `json:"s3_prefix" yaml:"s3_prefix" toml:"s3_prefix"`
+	S3AccessKey     string   `json:"s3_access_key" yaml:"s3_access_key" toml:"s3_access_key"`
+	S3SecretKey     string   `json:"s3_secret_key" yaml:"s3_secret_key" toml:"s3_secret_key"`
+	Categories      []string `json:"categories" yaml:"categories" toml:"categories"`
+	MaxConcurrent   int      `json:"max_concurrent" yaml:"max_concurrent" toml:"max_concurrent"`
+	MaxPerHost      int      `json:"max_per_host" yaml:"max_per_host" toml:"max_per_host"`
+	RateLimit       float64  `json:"rate_limit" yaml:"rate_limit" toml:"rate_limit"`
+	RateBurst       int      `json:"rate_burst" yaml:"rate_burst" toml:"rate_burst"`
+	ConnectTimeout  Duration `json:"connect_timeout" yaml:"connect_timeout" toml:"connect_timeout"`
+	ResponseTimeout Duration `json:"response_timeout" yaml:"response_timeout" toml:"response_timeout"`
+	RetryAttempts   int      `json:"retry_attempts" yaml:"retry_attempts" toml:"retry_attempts"`
+	UserAgent       string   `json:"user_agent" yaml:"user_agent" toml:"user_agent"`
+	IgnoreRobots    bool     `json:"ignore_robots" yaml:"ignore_robots" toml:"ignore_robots"`
+	ListenAddr      string   `json:"listen_addr" yaml:"listen_addr" toml:"listen_addr"`
+	Incremental     bool     `json:"incremental" yaml:"incremental" toml:"incremental"`
+	CheckpointFile  string   `json:"checkpoint_file" yaml:"checkpoint_file" toml:"checkpoint_file"`
+	Resume          bool     `json:"resume" yaml:"resume" toml:"resume"`
+	Discover        bool     `json:"discover" yaml:"discover" toml:"discover"`
+	DiscoverSeed    string   `json:"discover_seed" yaml:"discover_seed" toml:"discover_seed"`
+	CategoryAllow   []string `json:"category_allow" yaml:"category_allow" toml:"category_allow"`
+	CategoryDeny    []string `json:"category_deny" yaml:"category_deny" toml:"category_deny"`
+
+	// ColumnLabels bổ sung nhãn tiêu đề bảng cho từng trường (name, size, downloads, modified,
+	// uploaded_by), ví dụ khi thu thập trang tiếng Anh. Chỉ đặt được qua tệp cấu hình
+	ColumnLabels map[string][]string `json:"column_labels,omitempty" yaml:"column_labels,omitempty" toml:"column_labels,omitempty"`
+}
+
+// Duration là time.Duration đọc được từ chuỗi như "30s" trong YAML/TOML/JSON
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText phân tích chuỗi thời lượng theo cú pháp của time.ParseDuration
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalText xuất thời lượng dưới dạng chuỗi
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default trả về cấu hình mặc định, tương ứng với các hằng số trước đây
+func Default() *Config {
+	return &Config{
+		BaseURL:         "https://www.netcovn.com.vn",
+		HTMLDir:         "./respone",
+		DocumentsDir:    "./static/documents",
+		DataFile:        "./static/data.json",
+		Database:        "./data/netco.db",
+		QuarantineDir:   "./data/quarantine",
+		LinkMode:        "hardlink",
+		Storage:         "local",
+		S3Region:        "us-east-1",
+		Categories:      append([]string(nil), models.DefaultCategories...),
+		MaxConcurrent:   10,
+		MaxPerHost:      4,
+		RateLimit:       2,
+		RateBurst:       2,
+		ConnectTimeout:  Duration{30 * time.Second},
+		ResponseTimeout: Duration{60 * time.Second},
+		RetryAttempts:   4,
+		UserAgent:       utils.DefaultUserAgent,
+		ListenAddr:      ":8080",
+		CheckpointFile:  "./data/crawl.journal",
+		DiscoverSeed:    "/quan-he-co-dong",
+	}
+}
+
+// EnvPrefix là tiền tố của các biến môi trường cấu hình
+const EnvPrefix = "NETCO_"
+
+// field mô tả một khóa cấu hình có thể đặt qua biến môi trường và cờ dòng lệnh
+type field struct {
+	name  string // tên cờ, biến môi trường là EnvPrefix + name viết hoa, "-" thành "_"
+	usage string
+	get   func(c *Config) string
+	set   func(c *Config, value string) error
+}
+
+// fields liệt kê các khóa cấu hình theo cùng thứ tự với Config
+var fields = []field{
+	{"base-url", "Base URL of the Netco website",
+		func(c *Config) string { return c.BaseURL },
+		func(c *Config, v string) error { c.BaseURL = v; return nil }},
+	{"html-dir", "Cache of listing HTML used for pagination when the site is unreachable (empty = live only)",
+		func(c *Config) string { return c.HTMLDir },
+		func(c *Config, v string) error { c.HTMLDir = v; return nil }},
+	{"documents-dir", "Directory to store downloaded documents",
+		func(c *Config) string { return c.DocumentsDir },
+		func(c *Config, v string) error { c.DocumentsDir = v; return nil }},
+	{"data-file", "Path of the JSON export of the metadata (data.json), also imported into an empty database",
+		func(c *Config) string { return c.DataFile },
+		func(c *Config, v string) error { c.DataFile = v; return nil }},
+	{"database", "Path of the SQLite metadata database",
+		func(c *Config) string { return c.Database },
+		func(c *Config, v string) error { c.Database = v; return nil }},
+	{"quarantine-dir", "Directory for downloads rejected as HTML error pages (empty = discard them)",
+		func(c *Config) string { return c.QuarantineDir },
+		func(c *Config, v string) error { c.QuarantineDir = v; return nil }},
+	{"blob-dir", "Content-addressed store for downloads; documents-dir then holds links into it (empty = plain files)",
+		func(c *Config) string { return c.BlobDir },
+		func(c *Config, v string) error { c.BlobDir = v; return nil }},
+	{"link-mode", "How documents-dir links into blob-dir: hardlink or symlink",
+		func(c *Config) string { return c.LinkMode },
+		func(c *Config, v string) error { c.LinkMode = v; return nil }},
+	{"storage", "Where documents are stored and served from: local (documents-dir) or s3",
+		func(c *Config) string { return c.Storage },
+		func(c *Config, v string) error { c.Storage = v; return nil }},
+	{"s3-endpoint", "S3-compatible endpoint URL, e.g. http://localhost:9000 (path-style requests)",
+		func(c *Config) string { return c.S3Endpoint },
+		func(c *Config, v string) error { c.S3Endpoint = v; return nil }},
+	{"s3-region", "S3 region used for request signing",
+		func(c *Config) string { return c.S3Region },
+		func(c *Config, v string) error { c.S3Region = v; return nil }},
+	{"s3-bucket", "S3 bucket for documents",
+		func(c *Config) string { return c.S3Bucket },
+		func(c *Config, v string) error { c.S3Bucket = v; return nil }},
+	{"s3-prefix", "Key prefix inside the S3 bucket, e.g. netco/",
+		func(c *Config) string { return c.S3Prefix },
+		func(c *Config, v string) error { c.S3Prefix = v; return nil }},
+	{"s3-access-key", "S3 access key ID",
+		func(c *Config) string { return c.S3AccessKey },
+		func(c *Config, v string) error { c.S3AccessKey = v; return nil }},
+	{"s3-secret-key", "S3 secret access key (prefer the NETCO_S3_SECRET_KEY environment variable)",
+		func(c *Config) string { return "" },
+		func(c *Config, v string) error { c.S3SecretKey = v; return nil }},
+	{"categories", "Comma-separated list of categories to crawl",
+		func(c *Config) string { return strings.Join(c.Categories, ",") },
+		func(c *Config, v string) error { c.Categories = splitList(v); return nil }},
+	{"max-concurrent", "Maximum number of concurrent downloads",
+		func(c *Config) string { return strconv.Itoa(c.MaxConcurrent) },
+		func(c *Config, v string) error { return setInt(&c.MaxConcurrent, v) }},
+	{"max-per-host", "Maximum concurrent requests per host (0 = unlimited)",
+		func(c *Config) string { return strconv.Itoa(c.MaxPerHost) },
+		func(c *Config, v string) error { return setInt(&c.MaxPerHost, v) }},
+	{"rate-limit", "Requests per second to the server (0 = unlimited)",
+		func(c *Config) string { return strconv.FormatFloat(c.RateLimit, 'g', -1, 64) },
+		func(c *Config, v string) error { return setFloat(&c.RateLimit, v) }},
+	{"rate-burst", "Maximum burst of requests",
+		func(c *Config) string { return strconv.Itoa(c.RateBurst) },
+		func(c *Config, v string) error { return setInt(&c.RateBurst, v) }},
+	{"connect-timeout", "Timeout for establishing connections",
+		func(c *Config) string { return c.ConnectTimeout.String() },
+		func(c *Config, v string) error { return c.ConnectTimeout.UnmarshalText([]byte(v)) }},
+	{"response-timeout", "Timeout waiting for response headers",
+		func(c *Config) string { return c.ResponseTimeout.String() },
+		func(c *Config, v string) error { return c.ResponseTimeout.UnmarshalText([]byte(v)) }},
+	{"retry-attempts", "Total attempts for pages and downloads",
+		func(c *Config) string { return strconv.Itoa(c.RetryAttempts) },
+		func(c *Config, v string) error { return setInt(&c.RetryAttempts, v) }},
+	{"user-agent", "User-Agent string (with contact info) sent to the server",
+		func(c *Config) string { return c.UserAgent },
+		func(c *Config, v string) error { c.UserAgent = v; return nil }},
+	{"ignore-robots", "Ignore robots.txt (only for internal mirrors)",
+		func(c *Config) string { return strconv.FormatBool(c.IgnoreRobots) },
+		func(c *Config, v string) error { return setBool(&c.IgnoreRobots, v) }},
+	{"listen", "Web server listen address",
+		func(c *Config) string { return c.ListenAddr },
+		func(c *Config, v string) error { c.ListenAddr = v; return nil }},
+	{"incremental", "Only fetch new documents, merging them into the existing data file",
+		func(c *Config) string { return strconv.FormatBool(c.Incremental) },
+		func(c *Config, v string) error { return setBool(&c.Incremental, v) }},
+	{"checkpoint-file", "Path of the crawl checkpoint journal (empty = disabled)",
+		func(c *Config) string { return c.CheckpointFile },
+		func(c *Config, v string) error { c.CheckpointFile = v; return nil }},
+	{"resume", "Resume an interrupted crawl from the checkpoint journal",
+		func(c *Config) string { return strconv.FormatBool(c.Resume) },
+		func(c *Config, v string) error { return setBool(&c.Resume, v) }},
+	{"discover", "Discover categories from the site navigation in addition to the configured list",
+		func(c *Config) string { return strconv.FormatBool(c.Discover) },
+		func(c *Config, v string) error { return setBool(&c.Discover, v) }},
+	{"discover-seed", "Page whose menu and breadcrumb are scanned for categories",
+		func(c *Config) string { return c.DiscoverSeed },
+		func(c *Config, v string) error { c.DiscoverSeed = v; return nil }},
+	{"category-allow", "Comma-separated category patterns to crawl (empty = all)",
+		func(c *Config) string { return strings.Join(c.CategoryAllow, ",") },
+		func(c *Config, v string) error { c.CategoryAllow = splitList(v); return nil }},
+	{"category-deny", "Comma-separated category patterns to skip",
+		func(c *Config) string { return strings.Join(c.CategoryDeny, ",") },
+		func(c *Config, v string) error { c.CategoryDeny = splitList(v); return nil }},
+}
+
+// boolFields là các khóa dạng bật/tắt, có thể dùng cờ không kèm giá trị
+var boolFields = map[string]bool{
+	"ignore-robots": true,
+	"incremental":   true,
+	"resume":        true,
+	"discover":      true,
+}
+
+// Loader nạp cấu hình theo thứ tự ưu tiên: mặc định < tệp cấu hình < biến môi trường < cờ dòng lệnh
+type Loader struct {
+	fs         *flag.FlagSet
+	configPath *string
+	flagValues map[string]string
+}
+
+// NewLoader đăng ký các cờ cấu hình dùng chung lên fs.
+// Lệnh có thể đăng ký thêm cờ riêng lên fs trước khi gọi Load
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		fs:         fs,
+		flagValues: make(map[string]string),
+	}
+
+	defaults := Default()
+	l.configPath = fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "Path to a YAML, TOML or JSON config file")
+
+	for _, f := range fields {
+		name := f.name
+		usage := fmt.Sprintf("%s (default %q, env %s)", f.usage, f.get(defaults), envName(name))
+		if boolFields[name] {
+			fs.BoolFunc(name, usage, func(v string) error {
+				l.flagValues[name] = v
+				return nil
+			})
+			continue
+		}
+		fs.Func(name, usage, func(v string) error {
+			l.flagValues[name] = v
+			return nil
+		})
+	}
+
+	return l
+}
+
+// Load phân tích args, nạp tệp cấu hình, biến môi trường, cờ và kiểm tra tính hợp lệ
+func (l *Loader) Load(args []string) (*Config, error) {
+	if !l.fs.Parsed() {
+		if err := l.fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := Default()
+
+	if *l.configPath != "" {
+		if err := loadFile(*l.configPath, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if value, ok := os.LookupEnv(envName(f.name)); ok {
+			if err := f.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("biến môi trường %s không hợp lệ: %w", envName(f.name), err)
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if value, ok := l.flagValues[f.name]; ok {
+			if err := f.set(cfg, value); err != nil {
+				return nil, fmt.Errorf("cờ -%s không hợp lệ: %w", f.name, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile đọc tệp cấu hình theo phần mở rộng (.yaml, .yml, .toml, .json)
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("không thể đọc tệp cấu hình %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	case ".json":
+		err = json.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("định dạng tệp cấu hình không được hỗ trợ: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("không thể phân tích tệp cấu hình %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// columnFields là các trường của bảng danh sách có thể khai báo nhãn trong column_labels
+var columnFields = map[string]bool{
+	"name": true, "size": true, "downloads": true, "modified": true, "uploaded_by": true,
+}
+
+// Validate kiểm tra cấu hình và trả về tất cả lỗi tìm thấy
+func (c *Config) Validate() error {
+	var errs []error
+
+	if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("base_url phải là URL http(s) tuyệt đối: %q", c.BaseURL))
+	}
+	if c.DocumentsDir == "" {
+		errs = append(errs, errors.New("documents_dir không được để trống"))
+	}
+	if c.DataFile == "" {
+		errs = append(errs, errors.New("data_file không được để trống"))
+	}
+	if c.Database == "" {
+		errs = append(errs, errors.New("database không được để trống"))
+	}
+	if len(c.Categories) == 0 {
+		errs = append(errs, errors.New("categories phải có ít nhất một danh mục"))
+	}
+	for _, category := range c.Categories {
+		if category == "" || strings.ContainsAny(category, "/?#\\") {
+			errs = append(errs, fmt.Errorf("tên danh mục không hợp lệ: %q", category))
+		}
+	}
+	if c.MaxConcurrent < 1 {
+		errs = append(errs, fmt.Errorf("max_concurrent phải lớn hơn 0: %d", c.MaxConcurrent))
+	}
+	if c.MaxPerHost < 0 {
+		errs = append(errs, fmt.Errorf("max_per_host không được âm: %d", c.MaxPerHost))
+	}
+	if c.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit không được âm: %v", c.RateLimit))
+	}
+	if c.RateLimit > 0 && c.RateBurst < 1 {
+		errs = append(errs, fmt.Errorf("rate_burst phải lớn hơn 0 khi bật rate_limit: %d", c.RateBurst))
+	}
+	if c.ConnectTimeout.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("connect_timeout phải lớn hơn 0: %v", c.ConnectTimeout))
+	}
+	if c.ResponseTimeout.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("response_timeout phải lớn hơn 0: %v", c.ResponseTimeout))
+	}
+	if c.RetryAttempts < 1 {
+		errs = append(errs, fmt.Errorf("retry_attempts phải lớn hơn 0: %d", c.RetryAttempts))
+	}
+	if c.UserAgent == "" {
+		errs = append(errs, errors.New("user_agent không được để trống"))
+	}
+	if c.Resume && c.CheckpointFile == "" {
+		errs = append(errs, errors.New("resume cần checkpoint_file"))
+	}
+	if c.LinkMode != "hardlink" && c.LinkMode != "symlink" {
+		errs = append(errs, fmt.Errorf("link_mode phải là hardlink hoặc symlink: %q", c.LinkMode))
+	}
+	switch c.Storage {
+	case "local":
+	case "s3":
+		if u, err := url.Parse(c.S3Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("s3_endpoint phải là URL http(s) tuyệt đối: %q", c.S3Endpoint))
+		}
+		if c.S3Bucket == "" {
+			errs = append(errs, errors.New("s3_bucket không được để trống khi storage là s3"))
+		}
+		if c.BlobDir != "" {
+			errs = append(errs, errors.New("blob_dir chỉ dùng được với storage local"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage phải là local hoặc s3: %q", c.Storage))
+	}
+	if c.ListenAddr == "" {
+		errs = append(errs, errors.New("listen_addr không được để trống"))
+	}
+	if c.Discover && !strings.HasPrefix(c.DiscoverSeed, "/") {
+		errs = append(errs, fmt.Errorf("discover_seed phải là đường dẫn bắt đầu bằng \"/\": %q", c.DiscoverSeed))
+	}
+	for _, pattern := range append(append([]string(nil), c.CategoryAllow...), c.CategoryDeny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("mẫu danh mục không hợp lệ: %q", pattern))
+		}
+	}
+	for field := range c.ColumnLabels {
+		if !columnFields[field] {
+			errs = append(errs, fmt.Errorf("column_labels có trường không hợp lệ: %q", field))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("cấu hình không hợp lệ:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// envName trả về tên biến môi trường tương ứng với tên cờ
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// splitList tách danh sách phân tách bằng dấu phẩy, bỏ phần tử rỗng
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func setInt(dst *int, value string) error {
+	v, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
+
+func setFloat(dst *float64, value string) error {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
+
+func setBool(dst *bool, value string) error {
+	v, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
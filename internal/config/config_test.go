@@ -0,0 +1,221 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load nạp cấu hình như một lệnh với args, biến môi trường env và tệp cấu hình name có nội dung content
+// (bỏ qua tệp nếu name rỗng)
+func load(t *testing.T, args []string, env map[string]string, name, content string) (*Config, error) {
+	t.Helper()
+
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	if name != "" {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", path}, args...)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return NewLoader(fs).Load(args)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want int
+	}{
+		{name: "default", want: 10},
+		{name: "file", file: "max_concurrent: 3\n", want: 3},
+		{name: "env over file", file: "max_concurrent: 3\n", env: map[string]string{"NETCO_MAX_CONCURRENT": "5"}, want: 5},
+		{
+			name: "flag over env",
+			file: "max_concurrent: 3\n",
+			env:  map[string]string{"NETCO_MAX_CONCURRENT": "5"},
+			args: []string{"-max-concurrent", "7"},
+			want: 7,
+		},
+		{name: "flag over file", file: "max_concurrent: 3\n", args: []string{"-max-concurrent=7"}, want: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := ""
+			if tt.file != "" {
+				name = "config.yaml"
+			}
+			cfg, err := load(t, tt.args, tt.env, name, tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.MaxConcurrent != tt.want {
+				t.Errorf("MaxConcurrent = %d, want %d", cfg.MaxConcurrent, tt.want)
+			}
+			// Các khóa không được đặt vẫn giữ giá trị mặc định
+			if cfg.RateLimit != Default().RateLimit {
+				t.Errorf("RateLimit = %v, want default %v", cfg.RateLimit, Default().RateLimit)
+			}
+		})
+	}
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "config.yaml",
+			content: `base_url: https://mirror.example.com
+categories: [bao-cao-tai-chinh, cong-bo-thong-tin]
+connect_timeout: 5s
+ignore_robots: true
+column_labels:
+  name: [File name]
+`,
+		},
+		{
+			name: "config.yml",
+			content: `base_url: https://mirror.example.com
+categories:
+  - bao-cao-tai-chinh
+  - cong-bo-thong-tin
+connect_timeout: 5s
+ignore_robots: true
+column_labels:
+  name: [File name]
+`,
+		},
+		{
+			name: "config.toml",
+			content: `base_url = "https://mirror.example.com"
+categories = ["bao-cao-tai-chinh", "cong-bo-thong-tin"]
+connect_timeout = "5s"
+ignore_robots = true
+
+[column_labels]
+name = ["File name"]
+`,
+		},
+		{
+			name: "config.json",
+			content: `{
+  "base_url": "https://mirror.example.com",
+  "categories": ["bao-cao-tai-chinh", "cong-bo-thong-tin"],
+  "connect_timeout": "5s",
+  "ignore_robots": true,
+  "column_labels": {"name": ["File name"]}
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := load(t, nil, nil, tt.name, tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.BaseURL != "https://mirror.example.com" || !cfg.IgnoreRobots || cfg.ConnectTimeout.Duration != 5*time.Second {
+				t.Errorf("base_url=%q ignore_robots=%v connect_timeout=%v", cfg.BaseURL, cfg.IgnoreRobots, cfg.ConnectTimeout)
+			}
+			if want := []string{"bao-cao-tai-chinh", "cong-bo-thong-tin"}; !reflect.DeepEqual(cfg.Categories, want) {
+				t.Errorf("Categories = %v, want %v", cfg.Categories, want)
+			}
+			if want := map[string][]string{"name": {"File name"}}; !reflect.DeepEqual(cfg.ColumnLabels, want) {
+				t.Errorf("ColumnLabels = %v, want %v", cfg.ColumnLabels, want)
+			}
+		})
+	}
+
+	if _, err := load(t, nil, nil, "config.ini", "base_url = x"); err == nil || !strings.Contains(err.Error(), "không được hỗ trợ") {
+		t.Errorf("unsupported format: %v", err)
+	}
+}
+
+func TestLoadBadDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "file", file: "response_timeout: 30 giây\n", wantErr: "không thể phân tích tệp cấu hình"},
+		{name: "file without unit", file: "connect_timeout: \"30\"\n", wantErr: "không thể phân tích tệp cấu hình"},
+		{name: "env", env: map[string]string{"NETCO_CONNECT_TIMEOUT": "abc"}, wantErr: "NETCO_CONNECT_TIMEOUT"},
+		{name: "flag", args: []string{"-response-timeout", "1 phút"}, wantErr: "-response-timeout"},
+		{name: "zero", args: []string{"-connect-timeout", "0s"}, wantErr: "connect_timeout phải lớn hơn 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := ""
+			if tt.file != "" {
+				name = "config.yaml"
+			}
+			_, err := load(t, tt.args, tt.env, name, tt.file)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{"relative base url", func(c *Config) { c.BaseURL = "netcovn.com.vn" }, "base_url"},
+		{"empty documents dir", func(c *Config) { c.DocumentsDir = "" }, "documents_dir"},
+		{"no categories", func(c *Config) { c.Categories = nil }, "categories"},
+		{"category with slash", func(c *Config) { c.Categories = []string{"a/b"} }, "tên danh mục không hợp lệ"},
+		{"zero concurrency", func(c *Config) { c.MaxConcurrent = 0 }, "max_concurrent"},
+		{"negative rate limit", func(c *Config) { c.RateLimit = -1 }, "rate_limit"},
+		{"rate limit without burst", func(c *Config) { c.RateBurst = 0 }, "rate_burst"},
+		{"zero retry attempts", func(c *Config) { c.RetryAttempts = 0 }, "retry_attempts"},
+		{"resume without checkpoint", func(c *Config) { c.Resume, c.CheckpointFile = true, "" }, "checkpoint_file"},
+		{"unknown link mode", func(c *Config) { c.LinkMode = "copy" }, "link_mode"},
+		{"s3 without bucket", func(c *Config) { c.Storage, c.S3Endpoint = "s3", "http://localhost:9000" }, "s3_bucket"},
+		{"unknown storage", func(c *Config) { c.Storage = "ftp" }, "storage phải là"},
+		{"bad category pattern", func(c *Config) { c.CategoryDeny = []string{"[bao"} }, "mẫu danh mục"},
+		{"unknown column field", func(c *Config) { c.ColumnLabels = map[string][]string{"author": {"Tác giả"}} }, "column_labels"},
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Mọi lỗi được báo cùng lúc
+	cfg := Default()
+	cfg.MaxConcurrent, cfg.RetryAttempts = 0, 0
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "max_concurrent") || !strings.Contains(err.Error(), "retry_attempts") {
+		t.Errorf("Validate() = %v, want both errors", err)
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/netco-crawler/internal/database"
+)
+
+// OpenDatabase mở cơ sở dữ liệu siêu dữ liệu. Lần đầu mở (cơ sở dữ liệu chưa có tài liệu),
+// tệp DataFile của phiên bản cũ được nhập vào nếu có
+func (c *Config) OpenDatabase(ctx context.Context) (*database.DB, error) {
+	db, err := database.Open(c.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := db.ImportJSONIfEmpty(ctx, c.DataFile)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("không thể nhập %s: %w", c.DataFile, err)
+	}
+	if n > 0 {
+		log.Printf("Đã nhập %d tài liệu từ %s vào %s", n, c.DataFile, c.Database)
+	}
+	return db, nil
+}
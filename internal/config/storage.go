@@ -0,0 +1,22 @@
+package config
+
+import (
+	"github.com/netco-crawler/internal/storage"
+	"github.com/netco-crawler/internal/utils"
+)
+
+// OpenStorage tạo kho lưu tài liệu theo cấu hình: thư mục DocumentsDir hoặc dịch vụ tương thích S3
+func (c *Config) OpenStorage() (storage.Storage, error) {
+	if c.Storage != "s3" {
+		return storage.NewLocal(c.DocumentsDir), nil
+	}
+
+	return storage.NewS3(storage.S3Config{
+		Endpoint:  c.S3Endpoint,
+		Region:    c.S3Region,
+		Bucket:    c.S3Bucket,
+		Prefix:    c.S3Prefix,
+		AccessKey: c.S3AccessKey,
+		SecretKey: c.S3SecretKey,
+	}, utils.NewHTTPClient(c.ConnectTimeout.Duration, c.ResponseTimeout.Duration))
+}
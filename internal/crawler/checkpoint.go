@@ -0,0 +1,409 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/utils"
+)
+
+// Các loại bản ghi trong tệp checkpoint
+const (
+	entryPage     = "page"     // Một trang danh sách đã xử lý xong
+	entryCategory = "category" // Một danh mục đã thu thập xong
+	entryDownload = "download" // Kết quả tải một tài liệu
+	entryFinished = "finished" // Toàn bộ lần chạy đã hoàn tất
+)
+
+// Trạng thái tải xuống được ghi trong checkpoint
+const (
+	downloadDone   = "done"
+	downloadFailed = "failed"
+)
+
+// checkpointEntry là một dòng JSON trong tệp checkpoint
+type checkpointEntry struct {
+	Type      string                `json:"type"`
+	Time      time.Time             `json:"time"`
+	Category  string                `json:"category,omitempty"`
+	Page      int                   `json:"page,omitempty"`
+	Pages     int                   `json:"pages,omitempty"` // số trang của danh mục theo trang vừa tải
+	Documents []models.Document     `json:"documents,omitempty"`
+	Key       string                `json:"key,omitempty"`
+	Status    string                `json:"status,omitempty"`
+	Error     string                `json:"error,omitempty"`
+	File      *utils.DownloadResult `json:"file,omitempty"` // tệp đã lưu của bản ghi tải thành công
+}
+
+// checkpointSyncInterval là khoảng thời gian tối đa giữa hai lần đồng bộ checkpoint xuống đĩa.
+// Nếu tiến trình chết đột ngột, chỉ mất các bản ghi trong khoảng này và các tệp đó sẽ được tải lại
+const checkpointSyncInterval = time.Second
+
+// checkpoint ghi nhật ký tiến độ thu thập (trang đã duyệt, tài liệu tìm thấy, trạng thái tải)
+// dạng JSON Lines để có thể tiếp tục sau khi tiến trình bị dừng. Bản ghi được ghi qua bộ đệm
+// của một tệp luôn mở và đồng bộ định kỳ, khi kết thúc mỗi giai đoạn (sync) hoặc khi đóng.
+// Con trỏ nil nghĩa là không dùng checkpoint
+type checkpoint struct {
+	path       string
+	mu         sync.Mutex
+	pages      map[string]map[int][]models.Document
+	pageCounts map[string]int
+	categories map[string]bool
+	downloads  map[string]string
+	files      map[string]*utils.DownloadResult
+
+	file   *os.File
+	w      *bufio.Writer
+	synced time.Time // lần đồng bộ gần nhất
+}
+
+// WithCheckpoint ghi checkpoint vào path; resume = true sẽ tiếp tục từ checkpoint hiện có
+// thay vì bắt đầu lại từ đầu
+func WithCheckpoint(path string, resume bool) Option {
+	return func(c *Crawler) {
+		c.checkpointPath = path
+		c.resume = resume
+	}
+}
+
+// openCheckpoint mở tệp checkpoint. Khi resume, nạp lại tiến độ đã ghi;
+// ngược lại (hoặc khi lần chạy trước đã hoàn tất) tệp được làm mới
+func openCheckpoint(path string, resume bool) (*checkpoint, error) {
+	cp := &checkpoint{
+		path:       path,
+		pages:      make(map[string]map[int][]models.Document),
+		pageCounts: make(map[string]int),
+		categories: make(map[string]bool),
+		downloads:  make(map[string]string),
+		files:      make(map[string]*utils.DownloadResult),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("không thể tạo thư mục checkpoint: %w", err)
+	}
+
+	if resume {
+		finished, size, err := cp.load()
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.Printf("Không có checkpoint tại %s, bắt đầu lần chạy mới", path)
+		case err != nil:
+			return nil, err
+		case finished:
+			log.Printf("Checkpoint %s thuộc lần chạy đã hoàn tất, bắt đầu lần chạy mới", path)
+			cp.reset()
+		default:
+			// Cắt dòng cuối ghi dở để bản ghi mới bắt đầu trên dòng riêng
+			if err := os.Truncate(path, size); err != nil {
+				return nil, fmt.Errorf("không thể sửa tệp checkpoint: %w", err)
+			}
+			if err := cp.openFile(os.O_APPEND); err != nil {
+				return nil, err
+			}
+			log.Printf("Tiếp tục từ checkpoint %s: %d danh mục xong, %d tài liệu đã tải",
+				path, len(cp.categories), cp.countDownloaded())
+			return cp, nil
+		}
+	}
+
+	// Làm mới tệp checkpoint cho lần chạy mới
+	if err := cp.openFile(os.O_TRUNC); err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// openFile mở tệp checkpoint để ghi với cờ flag (O_APPEND hoặc O_TRUNC)
+func (cp *checkpoint) openFile(flag int) error {
+	file, err := os.OpenFile(cp.path, flag|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("không thể mở tệp checkpoint: %w", err)
+	}
+	cp.file = file
+	cp.w = bufio.NewWriter(file)
+	cp.synced = time.Now()
+	return nil
+}
+
+// load đọc lại các bản ghi đã ghi và trả về kích thước phần tệp gồm các dòng hoàn chỉnh.
+// Dòng cuối bị ghi dở (không kết thúc bằng xuống dòng) khi tiến trình chết sẽ được bỏ qua
+func (cp *checkpoint) load() (finished bool, size int64, err error) {
+	file, err := os.Open(cp.path)
+	if err != nil {
+		return false, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				log.Printf("Bỏ qua dòng cuối ghi dở của checkpoint (%d byte)", len(line))
+			}
+			break
+		}
+		if err != nil {
+			return false, 0, fmt.Errorf("không thể đọc checkpoint %s: %w", cp.path, err)
+		}
+		size += int64(len(line))
+
+		var entry checkpointEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Printf("Bỏ qua dòng checkpoint không hợp lệ: %v", err)
+			continue
+		}
+
+		switch entry.Type {
+		case entryPage:
+			if cp.pages[entry.Category] == nil {
+				cp.pages[entry.Category] = make(map[int][]models.Document)
+			}
+			cp.pages[entry.Category][entry.Page] = entry.Documents
+			if entry.Pages > 0 {
+				cp.pageCounts[entry.Category] = entry.Pages
+			}
+		case entryCategory:
+			cp.categories[entry.Category] = true
+		case entryDownload:
+			cp.downloads[entry.Key] = entry.Status
+			cp.files[entry.Key] = entry.File
+		case entryFinished:
+			finished = true
+		}
+	}
+
+	return finished, size, nil
+}
+
+// reset xóa tiến độ đã nạp trong bộ nhớ
+func (cp *checkpoint) reset() {
+	cp.pages = make(map[string]map[int][]models.Document)
+	cp.pageCounts = make(map[string]int)
+	cp.categories = make(map[string]bool)
+	cp.downloads = make(map[string]string)
+	cp.files = make(map[string]*utils.DownloadResult)
+}
+
+// append ghi thêm một bản ghi vào bộ đệm, đồng bộ xuống đĩa nếu đã quá checkpointSyncInterval
+func (cp *checkpoint) append(entry checkpointEntry) {
+	entry.Time = time.Now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Không thể mã hóa bản ghi checkpoint: %v", err)
+		return
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.w == nil {
+		return
+	}
+	if _, err := cp.w.Write(append(data, '\n')); err != nil {
+		log.Printf("Không thể ghi checkpoint: %v", err)
+		return
+	}
+	if time.Since(cp.synced) >= checkpointSyncInterval {
+		cp.flushLocked()
+	}
+}
+
+// flushLocked ghi bộ đệm và đồng bộ tệp xuống đĩa, phải gọi khi đang giữ mu
+func (cp *checkpoint) flushLocked() error {
+	cp.synced = time.Now()
+	if err := cp.w.Flush(); err != nil {
+		log.Printf("Không thể ghi checkpoint: %v", err)
+		return err
+	}
+	if err := cp.file.Sync(); err != nil {
+		log.Printf("Không thể đồng bộ checkpoint: %v", err)
+		return err
+	}
+	return nil
+}
+
+// sync ghi các bản ghi còn trong bộ đệm xuống đĩa
+func (cp *checkpoint) sync() error {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.w == nil {
+		return nil
+	}
+	return cp.flushLocked()
+}
+
+// close đồng bộ và đóng tệp checkpoint, các bản ghi sau đó bị bỏ qua
+func (cp *checkpoint) close() error {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.w == nil {
+		return nil
+	}
+	err := cp.flushLocked()
+	if cerr := cp.file.Close(); err == nil {
+		err = cerr
+	}
+	cp.file, cp.w = nil, nil
+	return err
+}
+
+// page trả về tài liệu của trang đã ghi trong checkpoint
+func (cp *checkpoint) page(category string, page int) ([]models.Document, bool) {
+	if cp == nil {
+		return nil, false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	docs, ok := cp.pages[category][page]
+	return docs, ok
+}
+
+// pageCount trả về số trang của danh mục ghi nhận gần nhất, 0 nếu chưa có
+func (cp *checkpoint) pageCount(category string) int {
+	if cp == nil {
+		return 0
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	return cp.pageCounts[category]
+}
+
+// recordPage ghi nhận một trang danh sách đã xử lý cùng số trang phát hiện trên trang đó
+func (cp *checkpoint) recordPage(category string, page, pages int, docs []models.Document) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	if cp.pages[category] == nil {
+		cp.pages[category] = make(map[int][]models.Document)
+	}
+	cp.pages[category][page] = docs
+	if pages > 0 {
+		cp.pageCounts[category] = pages
+	}
+	cp.mu.Unlock()
+
+	cp.append(checkpointEntry{Type: entryPage, Category: category, Page: page, Pages: pages, Documents: docs})
+}
+
+// categoryDocs trả về tài liệu của danh mục đã xong theo thứ tự trang, ok = false nếu danh mục chưa xong
+func (cp *checkpoint) categoryDocs(category string) ([]models.Document, bool) {
+	if cp == nil {
+		return nil, false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if !cp.categories[category] {
+		return nil, false
+	}
+
+	pages := make([]int, 0, len(cp.pages[category]))
+	for page := range cp.pages[category] {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+
+	var docs []models.Document
+	for _, page := range pages {
+		docs = append(docs, cp.pages[category][page]...)
+	}
+	return docs, true
+}
+
+// recordCategory ghi nhận một danh mục đã thu thập xong
+func (cp *checkpoint) recordCategory(category string) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	cp.categories[category] = true
+	cp.mu.Unlock()
+
+	cp.append(checkpointEntry{Type: entryCategory, Category: category})
+}
+
+// downloaded cho biết tài liệu đã được tải thành công theo checkpoint
+func (cp *checkpoint) downloaded(key string) bool {
+	if cp == nil {
+		return false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	return cp.downloads[key] == downloadDone
+}
+
+// downloadedFile trả về tệp đã lưu của tài liệu theo checkpoint, nil nếu không có
+func (cp *checkpoint) downloadedFile(key string) *utils.DownloadResult {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	return cp.files[key]
+}
+
+// recordDownload ghi nhận kết quả tải một tài liệu, file là tệp đã lưu khi tải thành công
+func (cp *checkpoint) recordDownload(key string, file *utils.DownloadResult, err error) {
+	if cp == nil {
+		return
+	}
+
+	entry := checkpointEntry{Type: entryDownload, Key: key, Status: downloadDone, File: file}
+	if err != nil {
+		entry.Status = downloadFailed
+		entry.Error = err.Error()
+		entry.File = nil
+	}
+
+	cp.mu.Lock()
+	cp.downloads[key] = entry.Status
+	cp.files[key] = entry.File
+	cp.mu.Unlock()
+
+	cp.append(entry)
+}
+
+// recordFinished đánh dấu lần chạy đã hoàn tất để lần --resume sau bắt đầu lại từ đầu
+func (cp *checkpoint) recordFinished() {
+	if cp == nil {
+		return
+	}
+	cp.append(checkpointEntry{Type: entryFinished})
+	cp.sync()
+}
+
+// countDownloaded đếm số tài liệu đã tải xong theo checkpoint
+func (cp *checkpoint) countDownloaded() int {
+	count := 0
+	for _, status := range cp.downloads {
+		if status == downloadDone {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,188 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/utils"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.journal")
+	docs := []models.Document{{Name: "BCTC 2024.pdf"}, {Name: "BCTC 2023.pdf"}}
+	file := &utils.DownloadResult{Path: "/tmp/BCTC 2024.pdf", Size: 3009, SHA256: "abc"}
+
+	cp, err := openCheckpoint(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.recordPage(testCategory, 2, 2, docs[1:])
+	cp.recordPage(testCategory, 1, 2, docs[:1])
+	cp.recordCategory(testCategory)
+	cp.recordPage("cong-bo-thong-tin", 1, 3, nil)
+	cp.recordDownload("site:418", file, nil)
+	cp.recordDownload("site:419", nil, errors.New("503"))
+	if err := cp.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lần chạy bị gián đoạn được tiếp tục từ các bản ghi đã ghi
+	cp, err = openCheckpoint(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.close()
+
+	got, ok := cp.categoryDocs(testCategory)
+	if !ok || len(got) != 2 || got[0].Name != "BCTC 2024.pdf" || got[1].Name != "BCTC 2023.pdf" {
+		t.Errorf("categoryDocs = %v, %v, want both pages in order", got, ok)
+	}
+	if _, ok := cp.categoryDocs("cong-bo-thong-tin"); ok {
+		t.Error("unfinished category reported as done")
+	}
+	if n := cp.pageCount("cong-bo-thong-tin"); n != 3 {
+		t.Errorf("pageCount = %d, want 3", n)
+	}
+	if !cp.downloaded("site:418") || cp.downloadedFile("site:418").SHA256 != "abc" {
+		t.Error("completed download not restored")
+	}
+	if cp.downloaded("site:419") || cp.downloadedFile("site:419") != nil {
+		t.Error("failed download restored as completed")
+	}
+}
+
+func TestCheckpointTruncatedLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.journal")
+
+	cp, err := openCheckpoint(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.recordDownload("site:418", &utils.DownloadResult{SHA256: "abc"}, nil)
+	cp.close()
+
+	// Tiến trình chết khi đang ghi bản ghi tiếp theo
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"download","key":"site:419","status":"do`)
+	f.Close()
+
+	cp, err = openCheckpoint(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.downloaded("site:418") || cp.downloaded("site:419") {
+		t.Errorf("downloads after truncated line = %v", cp.downloads)
+	}
+	// Bản ghi mới nằm trên dòng riêng, không dính vào phần ghi dở
+	cp.recordDownload("site:420", &utils.DownloadResult{SHA256: "def"}, nil)
+	cp.close()
+
+	cp, err = openCheckpoint(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.close()
+	if !cp.downloaded("site:418") || !cp.downloaded("site:420") || cp.downloaded("site:419") {
+		t.Errorf("downloads after second resume = %v", cp.downloads)
+	}
+}
+
+func TestCheckpointFinishedStartsOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.journal")
+
+	cp, err := openCheckpoint(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.recordCategory(testCategory)
+	cp.recordFinished()
+	cp.close()
+
+	cp, err = openCheckpoint(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.close()
+	if _, ok := cp.categoryDocs(testCategory); ok {
+		t.Error("finished checkpoint was resumed")
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("journal of the finished run not cleared: %v", err)
+	}
+}
+
+func TestResumeSkipsCompletedDownloads(t *testing.T) {
+	site := newFakeSite(t)
+	site.pages = 2
+	dir := t.TempDir()
+	journal := filepath.Join(t.TempDir(), "crawl.journal")
+
+	c := newTestCrawler(site, dir, WithCheckpoint(journal, false))
+	crawl(t, c)
+	c.Close()
+	var pending models.Document
+	for _, doc := range c.GetAllDocuments() {
+		if doc.FileID == 419 {
+			pending = doc
+		}
+	}
+	if pending.FilePath == "" {
+		t.Fatalf("document 419 not downloaded: %+v", c.GetAllDocuments())
+	}
+
+	// Lần chạy bị dừng trước khi tải xong tài liệu 419: bỏ bản ghi tải và bản ghi hoàn tất khỏi nhật ký
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Type == entryFinished || (entry.Type == entryDownload && entry.Key == pending.Key()) {
+			continue
+		}
+		kept = append(kept, scanner.Text())
+	}
+	if err := os.WriteFile(journal, []byte(strings.Join(kept, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, pending.FilePath)); err != nil {
+		t.Fatal(err)
+	}
+
+	site.mu.Lock()
+	downloads, listings := site.downloads, site.listings
+	site.mu.Unlock()
+
+	resumed := newTestCrawler(site, dir, WithCheckpoint(journal, true))
+	crawl(t, resumed)
+	resumed.Close()
+
+	site.mu.Lock()
+	defer site.mu.Unlock()
+	if got := site.downloads - downloads; got != 1 {
+		t.Errorf("resumed run downloaded %d files, want only the unfinished one", got)
+	}
+	if got := site.listings - listings; got != 0 {
+		t.Errorf("resumed run fetched %d listing pages of a finished category", got)
+	}
+	for _, doc := range resumed.GetAllDocuments() {
+		if doc.SHA256 == "" {
+			t.Errorf("document %s has no file after resume", doc.Name)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"fmt"
+
+	"github.com/netco-crawler/internal/blobstore"
+	"github.com/netco-crawler/internal/config"
+	"github.com/netco-crawler/internal/utils"
+)
+
+// NewCrawlerFromConfig tạo crawler từ cấu hình dùng chung, opts bổ sung được áp dụng sau cùng.
+// Trả về lỗi nếu không mở được kho tệp đã cấu hình thay vì âm thầm lưu vào chỗ khác
+func NewCrawlerFromConfig(cfg *config.Config, opts ...Option) (*Crawler, error) {
+	policy := utils.DefaultRetryPolicy()
+	policy.MaxAttempts = cfg.RetryAttempts
+
+	base := []Option{
+		WithCategories(cfg.Categories),
+		WithMaxConcurrent(cfg.MaxConcurrent),
+		WithMaxPerHost(cfg.MaxPerHost),
+		WithRateLimit(cfg.RateLimit, cfg.RateBurst),
+		WithHTTPClient(utils.NewHTTPClient(cfg.ConnectTimeout.Duration, cfg.ResponseTimeout.Duration)),
+		WithRetryPolicy(policy),
+		WithUserAgent(cfg.UserAgent),
+		WithIgnoreRobots(cfg.IgnoreRobots),
+		WithCategoryFilter(cfg.CategoryAllow, cfg.CategoryDeny),
+		WithQuarantineDir(cfg.QuarantineDir),
+	}
+	if cfg.Discover {
+		base = append(base, WithDiscovery(cfg.DiscoverSeed))
+	}
+	if cfg.CheckpointFile != "" {
+		base = append(base, WithCheckpoint(cfg.CheckpointFile, cfg.Resume))
+	}
+	if len(cfg.ColumnLabels) > 0 {
+		base = append(base, WithColumnLabels(ColumnLabels(cfg.ColumnLabels)))
+	}
+	if cfg.BlobDir != "" {
+		store, err := blobstore.New(cfg.BlobDir, blobstore.LinkMode(cfg.LinkMode))
+		if err != nil {
+			return nil, fmt.Errorf("không thể mở kho tệp %s: %w", cfg.BlobDir, err)
+		}
+		base = append(base, WithBlobStore(store))
+	}
+	store, err := cfg.OpenStorage()
+	if err != nil {
+		return nil, fmt.Errorf("không thể mở kho lưu trữ %s: %w", cfg.Storage, err)
+	}
+	base = append(base, WithStorage(store))
+	if cfg.Incremental {
+		base = append(base, WithIncremental(cfg.DataFile))
+	}
+
+	return NewCrawler(cfg.HTMLDir, cfg.DocumentsDir, cfg.BaseURL, append(base, opts...)...), nil
+}
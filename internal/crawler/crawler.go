@@ -1,30 +1,26 @@
 package crawler
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/netco-crawler/internal/blobstore"
+	"github.com/netco-crawler/internal/database"
 	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/robots"
+	"github.com/netco-crawler/internal/storage"
 	"github.com/netco-crawler/internal/utils"
 )
 
-// Các URL cơ sở cho các trang cần thu thập
-var baseCategories = []string{
-	"bao-cao-thuong-nien",
-	"bao-cao-tai-chinh",
-	"dieu-le-cong-ty",
-	"quy-che-quan-tri-cong-ty",
-	"cong-bao-thong-tin",
-	"ban-cao-bach",
-}
-
 // Crawler thực hiện thu thập dữ liệu từ các trang web của Netco
 type Crawler struct {
 	htmlDir        string
@@ -36,182 +32,321 @@ type Crawler struct {
 	maxConcurrent  int
 	duplicateMap   map[string]models.Document // Thay đổi từ map[string]bool thành map[string]models.Document để lưu trữ tài liệu
 	duplicateCount int                        // Số lượng tài liệu trùng lặp
+	fetcher        Fetcher                    // Thành phần tải trang và tệp
+	httpClient     *http.Client               // Client cho Fetcher mặc định
+	retryPolicy    utils.RetryPolicy          // Chính sách thử lại cho Fetcher mặc định
+	rateLimit      float64                    // Số yêu cầu mỗi giây tới máy chủ (0 = không giới hạn)
+	rateBurst      int                        // Số yêu cầu liên tiếp tối đa khi bucket đầy
+	maxPerHost     int                        // Số kết nối đồng thời tối đa tới mỗi host
+	limits         *requestLimits
+	userAgent      string           // Định danh của crawler gửi tới máy chủ
+	ignoreRobots   bool             // Bỏ qua robots.txt (chỉ dùng cho bản sao nội bộ)
+	ignoreDelay    bool             // Bỏ qua Crawl-delay của robots.txt (khi phát lại lưu trữ)
+	robots         *robots.Rules    // Luật robots.txt đã tải, nil nếu chưa tải
+	quarantineDir  string           // Thư mục cách ly trang lỗi trả về thay cho tệp, rỗng để xóa
+	blobs          *blobstore.Store // Kho tệp theo nội dung, nil nếu lưu tệp trực tiếp
+	storage        storage.Storage  // Kho lưu tài liệu, mặc định là documentsDir
+	db             *database.DB     // Cơ sở dữ liệu siêu dữ liệu, nil nếu không dùng
+	run            *database.Run    // Lần thu thập hiện tại để ghi các lần tải tệp
+
+	incrementalPath string                       // Tệp dữ liệu của lần chạy trước cho chế độ tăng dần
+	previous        map[string][]models.Document // Tài liệu của lần chạy trước theo danh mục
+	known           map[string]models.Document   // Tài liệu đã biết theo hash
+
+	stored map[string]models.Document // Tài liệu đã lưu trong cơ sở dữ liệu để phát hiện phiên bản mới
+
+	checkpointPath string      // Tệp nhật ký tiến độ, rỗng nếu không dùng
+	resume         bool        // Tiếp tục từ checkpoint của lần chạy bị gián đoạn
+	checkpoint     *checkpoint // nil nếu không dùng checkpoint
+
+	columnLabels  ColumnLabels   // Nhãn tiêu đề dùng để ánh xạ cột của bảng danh sách
+	parseWarnings []ParseWarning // Cảnh báo khi bảng danh sách thiếu cột
+	pageErrors    []*PageError   // Trang danh sách vẫn lỗi sau khi thử lại
+	complete      []string       // Danh mục đã thu thập đủ mọi trang danh sách
+
+	discoverySeed   string   // Trang gốc để phát hiện danh mục, rỗng nếu không phát hiện tự động
+	categoryAllow   []string // Mẫu danh mục được phép thu thập, rỗng = tất cả
+	categoryDeny    []string // Mẫu danh mục bị loại trừ
+	categoriesReady bool     // Đã phát hiện và lọc danh mục
+}
+
+// Option cấu hình tùy chọn cho Crawler
+type Option func(*Crawler)
+
+// WithFetcher thay thế Fetcher mặc định, ví dụ để trỏ tới máy chủ giả khi kiểm thử
+func WithFetcher(f Fetcher) Option {
+	return func(c *Crawler) {
+		c.fetcher = f
+	}
+}
+
+// WithHTTPClient dùng http.Client tùy chỉnh (timeout, proxy, transport) cho Fetcher mặc định
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Crawler) {
+		c.httpClient = client
+	}
+}
+
+// WithRetryPolicy thay đổi chính sách thử lại của Fetcher mặc định
+func WithRetryPolicy(policy utils.RetryPolicy) Option {
+	return func(c *Crawler) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithRateLimit giới hạn tốc độ yêu cầu theo token bucket (rps <= 0 để tắt)
+func WithRateLimit(rps float64, burst int) Option {
+	return func(c *Crawler) {
+		c.rateLimit = rps
+		c.rateBurst = burst
+	}
+}
+
+// WithMaxPerHost giới hạn số yêu cầu đồng thời tới mỗi host (n <= 0 để tắt)
+func WithMaxPerHost(n int) Option {
+	return func(c *Crawler) {
+		c.maxPerHost = n
+	}
+}
+
+// WithMaxConcurrent thay đổi số luồng tải xuống tối đa
+func WithMaxConcurrent(n int) Option {
+	return func(c *Crawler) {
+		if n > 0 {
+			c.maxConcurrent = n
+		}
+	}
+}
+
+// WithCategories thay đổi danh sách danh mục cần thu thập
+func WithCategories(categories []string) Option {
+	return func(c *Crawler) {
+		if len(categories) > 0 {
+			c.categories = append([]string(nil), categories...)
+		}
+	}
+}
+
+// WithUserAgent đặt chuỗi User-Agent (nên kèm thông tin liên hệ) cho Fetcher mặc định
+// và cho việc chọn nhóm luật trong robots.txt
+func WithUserAgent(ua string) Option {
+	return func(c *Crawler) {
+		if ua != "" {
+			c.userAgent = ua
+		}
+	}
+}
+
+// WithIgnoreRobots bỏ qua robots.txt, chỉ dùng cho bản sao nội bộ
+func WithIgnoreRobots(ignore bool) Option {
+	return func(c *Crawler) {
+		c.ignoreRobots = ignore
+	}
+}
+
+// WithIgnoreCrawlDelay bỏ qua Crawl-delay của robots.txt nhưng vẫn tuân theo Allow/Disallow.
+// Chỉ dùng khi phát lại lưu trữ HTTP, không có máy chủ thật để làm chậm
+func WithIgnoreCrawlDelay(ignore bool) Option {
+	return func(c *Crawler) {
+		c.ignoreDelay = ignore
+	}
+}
+
+// WithQuarantineDir đặt thư mục cách ly các phản hồi HTML (trang lỗi, trang đăng nhập)
+// mà máy chủ trả về thay cho tệp tài liệu, rỗng để xóa chúng. Chỉ áp dụng cho Fetcher mặc định
+func WithQuarantineDir(dir string) Option {
+	return func(c *Crawler) {
+		c.quarantineDir = dir
+	}
+}
+
+// WithBlobStore lưu tệp tải về vào kho theo nội dung (SHA-256); thư mục tài liệu chỉ chứa
+// liên kết tới kho nên các tệp giống hệt nhau chỉ chiếm dung lượng một lần
+func WithBlobStore(store *blobstore.Store) Option {
+	return func(c *Crawler) {
+		c.blobs = store
+	}
 }
 
 // NewCrawler tạo một crawler mới
-func NewCrawler(htmlDir, documentsDir, baseURL string) *Crawler {
-	// Đảm bảo thư mục tồn tại
-	if err := utils.EnsureDirectoryExists(htmlDir); err != nil {
-		log.Printf("Lỗi tạo thư mục HTML: %v, sẽ tiếp tục với thư mục hiện có", err)
+func NewCrawler(htmlDir, documentsDir, baseURL string, opts ...Option) *Crawler {
+	// Đảm bảo thư mục tồn tại. Thư mục HTML chỉ là bộ đệm, để trống để chỉ thu thập trực tiếp
+	if htmlDir != "" {
+		if err := utils.EnsureDirectoryExists(htmlDir); err != nil {
+			log.Printf("Lỗi tạo thư mục HTML: %v, sẽ tiếp tục với thư mục hiện có", err)
+		}
 	}
 
 	if err := utils.EnsureDirectoryExists(documentsDir); err != nil {
 		log.Printf("Lỗi tạo thư mục documents: %v, sẽ tiếp tục với thư mục hiện có", err)
 	}
 
-	return &Crawler{
+	c := &Crawler{
 		htmlDir:        htmlDir,
 		documentsDir:   documentsDir,
 		baseURL:        baseURL,
-		categories:     []string{"bao-cao-thuong-nien", "bao-cao-tai-chinh", "dieu-le-cong-ty", "quy-che-quan-tri-cong-ty", "cong-bao-thong-tin", "ban-cao-bach"},
+		categories:     append([]string(nil), models.DefaultCategories...),
 		documents:      make(map[string][]models.Document),
 		maxConcurrent:  10,                               // Tăng số luồng tải xuống tối đa từ 5 lên 10
 		duplicateMap:   make(map[string]models.Document), // Khởi tạo map phát hiện trùng lặp
 		duplicateCount: 0,
+		retryPolicy:    utils.DefaultRetryPolicy(),
+		rateLimit:      2, // Mặc định lịch sự: 2 yêu cầu/giây, tối đa 4 kết nối mỗi host
+		rateBurst:      2,
+		maxPerHost:     4,
+		userAgent:      utils.DefaultUserAgent,
+		columnLabels:   DefaultColumnLabels,
 	}
-}
 
-// ProcessHTMLFiles xử lý các tệp HTML đã cho để trích xuất thông tin tài liệu
-func (c *Crawler) ProcessHTMLFiles() error {
-	for _, category := range baseCategories {
-		// Đảm bảo thư mục đích tồn tại
-		categoryDir := filepath.Join(c.documentsDir, models.CategoryFolderMapping[category])
-		if err := utils.EnsureDirectoryExists(categoryDir); err != nil {
-			return fmt.Errorf("không thể tạo thư mục danh mục %s: %w", category, err)
-		}
+	for _, opt := range opts {
+		opt(c)
+	}
 
-		// Đọc tệp HTML để xác định số trang tối đa
-		htmlPath := filepath.Join(c.htmlDir, category+".html")
-		file, err := os.Open(htmlPath)
-		if err != nil {
-			return fmt.Errorf("không thể mở tệp HTML %s: %w", htmlPath, err)
-		}
+	if c.storage == nil {
+		c.storage = storage.NewLocal(documentsDir)
+	}
 
-		// Phân tích tài liệu HTML chỉ để lấy thông tin phân trang
-		doc, err := goquery.NewDocumentFromReader(file)
-		file.Close() // Đóng file sớm sau khi đọc xong
+	// Áp dụng giới hạn tốc độ và số kết nối mỗi host cho mọi yêu cầu: với Fetcher mặc định
+	// là từng yêu cầu HTTP (mỗi lần thử lại), với Fetcher tùy chỉnh là từng lần gọi
+	c.limits = &requestLimits{
+		limiter: utils.NewRateLimiter(c.rateLimit, c.rateBurst),
+		hosts:   utils.NewHostLimiter(c.maxPerHost),
+	}
+	if c.fetcher == nil {
+		fetcher := NewHTTPFetcher(limitedClient(c.httpClient, c.limits), c.retryPolicy, c.userAgent)
+		fetcher.downloader.QuarantineDir = c.quarantineDir
+		c.fetcher = fetcher
+	} else {
+		c.fetcher = &limitedFetcher{next: c.fetcher, limits: c.limits}
+	}
+
+	if c.checkpointPath != "" {
+		cp, err := openCheckpoint(c.checkpointPath, c.resume)
 		if err != nil {
-			return fmt.Errorf("không thể phân tích tệp HTML %s: %w", htmlPath, err)
+			log.Printf("Lỗi mở checkpoint: %v, sẽ tiếp tục mà không ghi checkpoint", err)
+		} else {
+			c.checkpoint = cp
 		}
+	}
 
-		// Kiểm tra phân trang
-		var maxPage int
-		doc.Find("a.ModulePager.LastPage").Each(func(i int, s *goquery.Selection) {
-			href, exists := s.Attr("href")
-			if !exists {
-				return
-			}
+	if c.ignoreRobots {
+		log.Println("!!! CẢNH BÁO: ĐANG BỎ QUA robots.txt. Chỉ sử dụng cho bản sao nội bộ được phép thu thập !!!")
+	}
 
-			// Trích xuất số trang cuối cùng từ URL
-			parts := strings.Split(href, "pagenumber=")
-			if len(parts) < 2 {
-				return
-			}
+	return c
+}
 
-			// Phân tích số trang
-			fmt.Sscanf(parts[1], "%d", &maxPage)
-		})
+// ProcessHTMLFiles xử lý các tệp HTML đã cho để trích xuất thông tin tài liệu
+func (c *Crawler) ProcessHTMLFiles() error {
+	return c.ProcessHTMLFilesContext(context.Background())
+}
 
-		if maxPage <= 0 {
-			maxPage = 1
+// ProcessHTMLFilesContext giống ProcessHTMLFiles nhưng có thể bị hủy qua ctx.
+// Khi bị hủy, các tài liệu đã thu thập được vẫn được giữ lại
+func (c *Crawler) ProcessHTMLFilesContext(ctx context.Context) error {
+	defer c.checkpoint.sync()
+	c.loadRobots(ctx)
+	c.prepareCategories(ctx)
+	incremental := c.prepareIncremental(ctx)
+	c.loadStoredDocuments(ctx)
+
+	for _, category := range c.categories {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		// Danh sách để lưu tất cả tài liệu từ danh mục này
-		var allCategoryDocs []models.Document
-
-		// Luôn bắt đầu từ trang 1 với tham số pagenumber=1
-		for page := 1; page <= maxPage; page++ {
-			// Xây dựng URL với tham số pagenumber cho tất cả các trang, kể cả trang 1
-			pageURL := fmt.Sprintf("%s/%s?pagenumber=%d", c.baseURL, category, page)
-			log.Printf("Đang xử lý trang %d/%d của danh mục %s", page, maxPage, category)
-
-			// Tải trang HTML
-			resp, err := http.Get(pageURL)
-			if err != nil {
-				log.Printf("Lỗi khi tải trang %d của danh mục %s: %v", page, category, err)
-				continue
-			}
-
-			if resp.StatusCode != http.StatusOK {
-				log.Printf("Trang %d của danh mục %s trả về mã trạng thái không thành công: %d", page, category, resp.StatusCode)
-				resp.Body.Close()
-				continue
-			}
-
-			// Phân tích tài liệu HTML
-			pageDoc, err := goquery.NewDocumentFromReader(resp.Body)
-			resp.Body.Close()
-			if err != nil {
-				log.Printf("Không thể phân tích trang %d của danh mục %s: %v", page, category, err)
-				continue
-			}
-
-			// Trích xuất tài liệu từ trang này
-			var pageDocs []models.Document
-			extractDocumentsFromHTML(pageDoc, category, &pageDocs)
+		// Đảm bảo thư mục đích tồn tại
+		categoryDir := filepath.Join(c.documentsDir, models.CategoryFolder(category))
+		if err := utils.EnsureDirectoryExists(categoryDir); err != nil {
+			return fmt.Errorf("không thể tạo thư mục danh mục %s: %w", category, err)
+		}
 
-			// Kiểm tra trang rỗng
-			if len(pageDocs) == 0 {
-				log.Printf("Trang %d của danh mục %s không có tài liệu nào, dừng phân trang", page, category)
-				break
+		// Danh mục đã thu thập xong trong lần chạy trước được lấy lại từ checkpoint
+		if docs, ok := c.checkpoint.categoryDocs(category); ok {
+			log.Printf("Danh mục %s đã thu thập xong theo checkpoint (%d tài liệu)", category, len(docs))
+			c.observe(ctx, docs)
+			if incremental {
+				docs = c.mergeWithPrevious(category, docs)
+			} else {
+				c.applyStoredFiles(docs)
 			}
+			models.ResolveFilePaths(docs)
+			c.mu.Lock()
+			c.documents[category] = docs
+			c.complete = append(c.complete, category)
+			c.mu.Unlock()
+			continue
+		}
 
-			log.Printf("Tìm thấy %d tài liệu trong danh mục %s (trang %d/%d)", len(pageDocs), category, page, maxPage)
+		allCategoryDocs, complete, err := c.crawlCategory(ctx, category, incremental)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		c.observe(ctx, allCategoryDocs)
 
-			// Thêm tài liệu từ trang này vào danh sách
-			allCategoryDocs = append(allCategoryDocs, pageDocs...)
+		if incremental {
+			allCategoryDocs = c.mergeWithPrevious(category, allCategoryDocs)
+		} else {
+			c.applyStoredFiles(allCategoryDocs)
 		}
+		models.ResolveFilePaths(allCategoryDocs)
 
 		// Lưu tài liệu vào bản đồ
 		c.mu.Lock()
 		c.documents[category] = allCategoryDocs
 		c.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			log.Printf("Đã hủy thu thập tại danh mục %s, giữ lại %d tài liệu đã tìm thấy", category, len(allCategoryDocs))
+			return err
+		}
+		// Danh mục có trang lỗi hoặc bị chặn sẽ được thu thập lại khi tiếp tục từ checkpoint
+		if complete {
+			c.checkpoint.recordCategory(category)
+			c.mu.Lock()
+			c.complete = append(c.complete, category)
+			c.mu.Unlock()
+		}
 	}
 
 	return nil
 }
 
-// Tách logic trích xuất tài liệu từ HTML thành một hàm riêng
-func extractDocumentsFromHTML(doc *goquery.Document, category string, docs *[]models.Document) {
-	doc.Find("table tbody tr").Each(func(i int, s *goquery.Selection) {
-		var document models.Document
-
-		// Tên và URL tải xuống
-		nameCell := s.Find("td").First()
-		linkElem := nameCell.Find("a").First()
-		document.Name = strings.TrimSpace(linkElem.Text())
-		if document.Name == "" {
-			// Bỏ qua hàng không có tên tài liệu
-			return
-		}
-
-		document.DownloadURL, _ = linkElem.Attr("href")
-
-		// Kích thước
-		document.Size = strings.TrimSpace(nameCell.Next().Text())
-
-		// Số lượt tải
-		document.Downloads = strings.TrimSpace(nameCell.Next().Next().Text())
-
-		// Ngày sửa đổi
-		document.Modified = strings.TrimSpace(nameCell.Next().Next().Next().Text())
-
-		// Người tải lên
-		document.UploadedBy = strings.TrimSpace(nameCell.Next().Next().Next().Next().Text())
-
-		// Danh mục
-		document.Category = category
+// fetchListingPage tải một trang danh sách và trích xuất các tài liệu trên trang
+// cùng số trang của danh mục hiển thị trên trang đó (0 nếu không có phân trang)
+func (c *Crawler) fetchListingPage(ctx context.Context, pageURL, category string, page int) ([]models.Document, int, error) {
+	body, err := c.fetcher.FetchPage(ctx, pageURL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("không thể tải trang: %w", err)
+	}
 
-		// Đường dẫn tệp cục bộ
-		document.FilePath = filepath.Join(
-			models.CategoryFolderMapping[category],
-			document.FileName(),
-		)
+	// Trang đầu được lưu vào bộ đệm HTML để dùng khi trang web không truy cập được
+	var reader io.Reader = body
+	var cache *bytes.Buffer
+	if page == 1 && c.htmlDir != "" {
+		cache = new(bytes.Buffer)
+		reader = io.TeeReader(body, cache)
+	}
 
-		*docs = append(*docs, document)
-	})
-}
+	pageDoc, err := goquery.NewDocumentFromReader(reader)
+	body.Close()
+	if err != nil {
+		return nil, 0, fmt.Errorf("không thể phân tích trang: %w", err)
+	}
+	if cache != nil {
+		c.cacheListingPage(category, cache.Bytes())
+	}
 
-// generateDocumentHash tạo chuỗi hash để xác định tài liệu trùng lặp
-func (c *Crawler) generateDocumentHash(doc models.Document) string {
-	// Tạo hash dựa trên 4 trường: name, download_url, category, file_path
-	return fmt.Sprintf("%s_%s_%s_%s", doc.Name, doc.DownloadURL, doc.Category, doc.FilePath)
+	var pageDocs []models.Document
+	warnings := extractDocumentsFromHTML(pageDoc, category, c.columnLabels, &pageDocs)
+	c.recordWarnings(page, warnings)
+	return pageDocs, parsePageCount(pageDoc), nil
 }
 
 // isDuplicate kiểm tra xem tài liệu có phải là bản sao không
 // Nếu là bản sao, quyết định giữ lại tài liệu nào dựa trên số lượng trường thông tin rỗng
 func (c *Crawler) isDuplicate(doc models.Document) bool {
-	hash := c.generateDocumentHash(doc)
+	hash := doc.Key()
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -266,7 +401,16 @@ func countEmptyFields(doc models.Document) int {
 
 // DownloadDocuments tải xuống tất cả các tài liệu
 func (c *Crawler) DownloadDocuments() error {
+	return c.DownloadDocumentsContext(context.Background())
+}
+
+// DownloadDocumentsContext giống DownloadDocuments nhưng có thể bị hủy qua ctx.
+// Khi bị hủy, không khởi chạy thêm lượt tải mới và chờ các goroutine đang chạy kết thúc
+func (c *Crawler) DownloadDocumentsContext(ctx context.Context) error {
+	defer c.checkpoint.sync()
 	log.Println("Bắt đầu tải các tài liệu...")
+	c.loadRobots(ctx)
+	c.loadStoredDocuments(ctx)
 
 	// Kiểm tra xem có tài liệu để tải không
 	if len(c.documents) == 0 {
@@ -292,30 +436,64 @@ func (c *Crawler) DownloadDocuments() error {
 	var downloadedDocs int
 	var downloadMutex sync.Mutex
 
+	// advance đếm một tài liệu đã xử lý xong (tải, bỏ qua hoặc lỗi) và ghi log tiến độ
+	advance := func() {
+		downloadMutex.Lock()
+		defer downloadMutex.Unlock()
+		downloadedDocs++
+		progress := float64(downloadedDocs) / float64(totalDocs) * 100
+		log.Printf("Tiến độ: %.1f%% (%d/%d)", progress, downloadedDocs, totalDocs)
+	}
+
 	// Đặt lại biến đếm trùng lặp
 	c.duplicateCount = 0
 	// Đặt lại map phát hiện trùng lặp để bắt đầu mới
 	c.duplicateMap = make(map[string]models.Document)
 
 	// Tải tài liệu theo danh mục
+categoryLoop:
 	for category, docs := range c.documents {
 		log.Printf("Đang tải %d tài liệu từ danh mục %s", len(docs), category)
 
 		for i, doc := range docs {
+			if ctx.Err() != nil {
+				break categoryLoop
+			}
+
 			// Kiểm tra trùng lặp và quyết định giữ lại tài liệu nào
 			isDup := c.isDuplicate(doc)
 			if isDup {
 				log.Printf("Phát hiện tài liệu trùng lặp, bỏ qua: %s", doc.Name)
-				downloadMutex.Lock()
-				downloadedDocs++
-				progress := float64(downloadedDocs) / float64(totalDocs) * 100
-				log.Printf("Tiến độ: %.1f%% (%d/%d)", progress, downloadedDocs, totalDocs)
-				downloadMutex.Unlock()
+				advance()
+				continue
+			}
+
+			if !c.allowedByRobots(doc.DownloadURL) {
+				log.Printf("robots.txt không cho phép tải %s, bỏ qua", doc.DownloadURL)
+				c.recordDownloadError(doc, errDisallowedByRobots)
+				advance()
 				continue
 			}
 
+			// Tài liệu được tải lên lại (ngày sửa đổi hoặc kích thước đã đổi) luôn được tải về thành phiên bản mới
+			prev, changed := c.changedVersion(doc)
+
+			// Tài liệu đã tải xong theo checkpoint và tệp trong kho còn đúng kích thước thì không tải lại
+			if file := c.checkpoint.downloadedFile(doc.Key()); file != nil && c.checkpoint.downloaded(doc.Key()) && !changed {
+				if info, err := c.storage.Stat(ctx, c.storageKey(file.Path)); err == nil && info.Size == file.Size {
+					c.recordDownloadedFile(doc, file)
+					advance()
+					continue
+				}
+			}
+
+			// Lấy token hoặc dừng nếu bị hủy
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				break categoryLoop
+			}
 			wg.Add(1)
-			semaphore <- struct{}{} // Lấy token
 
 			go func(index int, document models.Document) {
 				defer wg.Done()
@@ -323,29 +501,81 @@ func (c *Crawler) DownloadDocuments() error {
 
 				destPath := filepath.Join(c.documentsDir, document.FilePath)
 
-				// Nếu tệp đã tồn tại, bỏ qua tải xuống
-				if _, err := os.Stat(destPath); err == nil {
+				// Danh sách không đổi nhưng nội dung tệp có thể đã bị thay trên máy chủ
+				if !changed {
+					prev, changed = c.contentChanged(ctx, document)
+				}
+
+				var versionKey string
+				if changed {
+					// Sao tệp của phiên bản cũ trước khi tải phiên bản mới, tệp cũ chỉ bị thay khi tải xong
+					log.Printf("Tài liệu đã thay đổi (sửa đổi %s, %s KB), tải phiên bản mới: %s", document.Modified, document.Size, document.Name)
+					var err error
+					if versionKey, err = c.stageVersion(ctx, prev); err != nil {
+						log.Printf("Lỗi khi lưu giữ phiên bản cũ của %s: %v", document.Name, err)
+						if ctx.Err() == nil {
+							c.recordDownloadError(document, err)
+						}
+						return
+					}
+				} else if c.remoteStorage() {
+					// Tệp đã có trong kho lưu trữ từ xa thì không tải lại
+					if file, ok := c.storedFile(ctx, document); ok {
+						log.Printf("Tệp đã có trong kho lưu trữ, bỏ qua tải xuống: %s", document.FilePath)
+						c.recordDownloadedFile(document, file)
+						advance()
+						return
+					}
+				}
+
+				// Nếu tệp đã tồn tại, khớp kích thước trong danh sách và không phải trang lỗi, bỏ qua tải xuống
+				if file, err := utils.LocalFileInfo(destPath); err == nil && !changed && document.SizeMatches(file.Size) && !isErrorPage(destPath) {
 					log.Printf("Tệp đã tồn tại, bỏ qua tải xuống: %s", destPath)
-					downloadMutex.Lock()
-					downloadedDocs++
-					progress := float64(downloadedDocs) / float64(totalDocs) * 100
-					log.Printf("Tiến độ: %.1f%% (%d/%d)", progress, downloadedDocs, totalDocs)
-					downloadMutex.Unlock()
+					c.storeBlob(file)
+					if err := c.publish(ctx, file); err != nil {
+						log.Printf("Lỗi khi lưu tệp %s: %v", document.Name, err)
+						c.recordDownloadError(document, err)
+						return
+					}
+					c.recordDownloadedFile(document, file)
+					advance()
 					return
+				} else if err == nil && !changed {
+					log.Printf("Tệp %s có %d byte, không khớp kích thước %s KB trong danh sách hoặc là trang lỗi, tải lại", destPath, file.Size, document.Size)
 				}
 
 				// Tải tệp
 				log.Printf("Đang tải: %s", document.Name)
-				if err := utils.DownloadFile(document.DownloadURL, destPath); err != nil {
+				started := time.Now()
+				file, err := c.fetcher.FetchFile(ctx, document.DownloadURL, destPath)
+				c.recordAttempt(ctx, document, started, file, err)
+				if err != nil {
 					log.Printf("Lỗi khi tải tệp %s: %v", document.Name, err)
+					c.discardVersion(versionKey)
+					if ctx.Err() == nil {
+						c.recordDownloadError(document, err)
+						c.checkpoint.recordDownload(document.Key(), nil, err)
+					}
 					return
 				}
+				c.storeBlob(file)
+				if err := c.publish(ctx, file); err != nil {
+					log.Printf("Lỗi khi lưu tệp %s: %v", document.Name, err)
+					c.discardVersion(versionKey)
+					if ctx.Err() == nil {
+						c.recordDownloadError(document, err)
+						c.checkpoint.recordDownload(document.Key(), nil, err)
+					}
+					return
+				}
+				if changed {
+					c.finishVersion(ctx, prev, versionKey, file)
+				}
+				c.recordDownloadError(document, nil)
+				c.recordDownloadedFile(document, file)
+				c.checkpoint.recordDownload(document.Key(), file, nil)
 
-				downloadMutex.Lock()
-				downloadedDocs++
-				progress := float64(downloadedDocs) / float64(totalDocs) * 100
-				log.Printf("Tiến độ: %.1f%% (%d/%d)", progress, downloadedDocs, totalDocs)
-				downloadMutex.Unlock()
+				advance()
 
 				log.Printf("Đã tải xong: %s", document.Name)
 			}(i, doc)
@@ -354,16 +584,107 @@ func (c *Crawler) DownloadDocuments() error {
 
 	// Đợi tất cả tải xuống hoàn tất
 	wg.Wait()
-	log.Printf("Đã tải xuống tất cả tài liệu. Tổng số: %d", totalDocs)
-	log.Printf("Phát hiện %d tài liệu trùng lặp trong cơ sở dữ liệu", c.duplicateCount)
 
-	// Cập nhật lại danh sách tài liệu để loại bỏ các bản trùng lặp
+	// Cập nhật lại danh sách tài liệu để loại bỏ các bản trùng lặp và ghi nhận tệp đã tải,
+	// kể cả khi bị hủy để kết quả được lưu không mất các tệp đã tải xong
 	c.updateDocumentsFromDuplicateMap()
 
+	if err := ctx.Err(); err != nil {
+		log.Printf("Đã hủy tải xuống sau %d/%d tài liệu", downloadedDocs, totalDocs)
+		return err
+	}
+
+	log.Printf("Đã tải xuống tất cả tài liệu. Tổng số: %d", totalDocs)
+	log.Printf("Phát hiện %d tài liệu trùng lặp trong cơ sở dữ liệu", c.duplicateCount)
+	c.checkpoint.recordFinished()
+
 	return nil
 }
 
-// updateDocumentsFromDuplicateMap cập nhật lại danh sách tài liệu từ map trùng lặp
+// recordDownloadError ghi nhận lỗi tải xuống cuối cùng lên tài liệu (nil để xóa lỗi cũ)
+func (c *Crawler) recordDownloadError(doc models.Document, err error) {
+	hash := doc.Key()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored, ok := c.duplicateMap[hash]
+	if !ok {
+		return
+	}
+
+	if err != nil {
+		stored.DownloadError = err.Error()
+	} else {
+		stored.DownloadError = ""
+	}
+
+	// Trang lỗi trả về thay cho tệp: ghi lại nơi cách ly để kiểm tra
+	stored.QuarantinePath = ""
+	var invalid *utils.InvalidContentError
+	if errors.As(err, &invalid) {
+		stored.QuarantinePath = invalid.Path
+	}
+	c.duplicateMap[hash] = stored
+}
+
+// storeBlob đưa tệp đã tải vào kho theo nội dung (nếu dùng) và thay bằng liên kết tới kho
+func (c *Crawler) storeBlob(file *utils.DownloadResult) {
+	if c.blobs == nil {
+		return
+	}
+	if err := c.blobs.Adopt(file.Path, file.SHA256); err != nil {
+		log.Printf("Lỗi khi đưa %s vào kho tệp: %v, giữ tệp riêng", file.Path, err)
+	}
+}
+
+// isErrorPage cho biết tệp đã có trên đĩa là trang HTML hoặc trang lỗi lưu nhầm thay cho tài liệu
+func isErrorPage(path string) bool {
+	reason, err := utils.DetectErrorPage(path, "", path)
+	return err == nil && reason != ""
+}
+
+// recordDownloadedFile ghi tên tệp đã chọn, tên gốc của máy chủ, kiểu MIME và mã băm lên tài liệu.
+// Kích thước thực được so với cột "Kích thước (KB)" của danh sách
+func (c *Crawler) recordDownloadedFile(doc models.Document, file *utils.DownloadResult) {
+	hash := doc.Key()
+	mismatch := !doc.SizeMatches(file.Size)
+	if mismatch {
+		log.Printf("Cảnh báo: %s có %d byte, không khớp kích thước %s KB trong danh sách", file.Path, file.Size, doc.Size)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stored, ok := c.duplicateMap[hash]
+	if !ok {
+		return
+	}
+
+	if rel, err := filepath.Rel(c.documentsDir, file.Path); err == nil {
+		stored.FilePath = rel
+	}
+	stored.StoredFileName = file.FileName
+	if file.ServerFileName != "" {
+		stored.ServerFileName = file.ServerFileName
+	}
+	if file.MIMEType != "" {
+		stored.MIMEType = file.MIMEType
+	}
+	if file.SHA256 != "" {
+		stored.SHA256 = file.SHA256
+	}
+	// Tệp lấy lại từ đĩa hoặc kho không có header của máy chủ, giữ giá trị của lần tải trước
+	if file.ETag != "" || file.LastModified != "" {
+		stored.ETag, stored.LastModified = file.ETag, file.LastModified
+	}
+	stored.FileSize = file.Size
+	stored.SizeMismatch = mismatch
+	c.duplicateMap[hash] = stored
+}
+
+// updateDocumentsFromDuplicateMap cập nhật lại danh sách tài liệu từ map trùng lặp. Tài liệu chưa
+// được xét vì lượt tải bị hủy giữa chừng được giữ nguyên như trong danh sách
 func (c *Crawler) updateDocumentsFromDuplicateMap() {
 	// Tạo map mới để lưu trữ tài liệu đã lọc
 	newDocuments := make(map[string][]models.Document)
@@ -374,17 +695,50 @@ func (c *Crawler) updateDocumentsFromDuplicateMap() {
 		newDocuments[category] = append(newDocuments[category], doc)
 	}
 
+	// Thêm các tài liệu chưa được xét, mỗi tài liệu một lần
+	pending := make(map[string]bool)
+	for category, docs := range c.documents {
+		for _, doc := range docs {
+			hash := doc.Key()
+			if _, seen := c.duplicateMap[hash]; seen || pending[hash] {
+				continue
+			}
+			pending[hash] = true
+			newDocuments[category] = append(newDocuments[category], doc)
+		}
+	}
+
 	// Cập nhật lại danh sách tài liệu
 	c.documents = newDocuments
 
 	log.Printf("Đã cập nhật lại danh sách tài liệu sau khi loại bỏ %d bản trùng lặp", c.duplicateCount)
 }
 
+// Close ghi nốt và đóng tệp checkpoint (nếu dùng)
+func (c *Crawler) Close() error {
+	return c.checkpoint.close()
+}
+
 // GetDocuments trả về tất cả tài liệu đã trích xuất
 func (c *Crawler) GetDocuments() map[string][]models.Document {
 	return c.documents
 }
 
+// Result trả về kết quả của lần chạy để lưu vào cơ sở dữ liệu: tài liệu, các danh mục đã thu thập đủ
+// (chỉ những danh mục này được thay thế) và lỗi trang danh sách. canceled là lỗi nếu lần chạy bị hủy
+func (c *Crawler) Result(canceled error) database.Result {
+	c.mu.Lock()
+	complete := append([]string(nil), c.complete...)
+	c.mu.Unlock()
+
+	return database.Result{
+		Documents:  c.documents,
+		Complete:   complete,
+		Canceled:   canceled,
+		Incomplete: c.Incomplete(),
+	}
+}
+
 // GetAllDocuments trả về danh sách phẳng của tất cả tài liệu
 func (c *Crawler) GetAllDocuments() []models.Document {
 	var allDocs []models.Document
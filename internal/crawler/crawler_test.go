@@ -0,0 +1,465 @@
+package crawler
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/netco-crawler/internal/database"
+	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/utils"
+)
+
+const testCategory = "bao-cao-tai-chinh"
+
+// fakeSite là bản giả của netcovn.com.vn: robots.txt, các trang danh sách và Download.aspx
+type fakeSite struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	robots      string      // nội dung robots.txt, rỗng để trả về 404
+	pages       int         // số trang danh sách, trang n có tài liệu fileid 417+n
+	pageStatus  map[int]int // mã lỗi trả về cho từng trang danh sách
+	modified    string      // cột "Đã sửa đổi" của tài liệu
+	sizeKB      string      // cột "Kích thước (KB)" của tài liệu
+	body        string      // nội dung tệp tải về
+	disposition string      // header Content-Disposition của tệp, rỗng nếu không gửi
+	fileStatus  int         // mã lỗi Download.aspx trả về, 0 để trả tệp
+	downloads   int         // số lần tệp được tải (GET Download.aspx)
+	listings    int         // số lần trang danh sách được tải
+	userAgents  []string
+}
+
+func newFakeSite(t *testing.T) *fakeSite {
+	t.Helper()
+
+	site := &fakeSite{
+		pages:    1,
+		modified: "17/01/2025 15:08:11",
+		sizeKB:   "3",
+		body:     "%PDF-1.4 " + strings.Repeat("a", 3000),
+	}
+	site.Server = httptest.NewServer(http.HandlerFunc(site.serve))
+	t.Cleanup(site.Close)
+	return site
+}
+
+func (s *fakeSite) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userAgents = append(s.userAgents, r.UserAgent())
+
+	switch {
+	case r.URL.Path == "/robots.txt":
+		if s.robots == "" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, s.robots)
+	case r.URL.Path == "/SharedFiles/Download.aspx":
+		if r.Method == http.MethodGet {
+			s.downloads++
+		}
+		if s.fileStatus != 0 {
+			http.Error(w, "Service Unavailable", s.fileStatus)
+			return
+		}
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(s.body)))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		if s.disposition != "" {
+			w.Header().Set("Content-Disposition", s.disposition)
+		}
+		fmt.Fprint(w, s.body)
+	case r.URL.Path == "/"+testCategory:
+		s.listings++
+		page, err := strconv.Atoi(r.URL.Query().Get("pagenumber"))
+		if err != nil || page < 1 || page > s.pages {
+			http.NotFound(w, r)
+			return
+		}
+		if status := s.pageStatus[page]; status != 0 {
+			http.Error(w, "Service Unavailable", status)
+			return
+		}
+		name := fmt.Sprintf("BCTC %d.pdf", 2025-page)
+		fmt.Fprintf(w, `<html><body><table>
+<thead><tr><th>Tên tập tin</th><th>Kích thước (KB)</th><th>Đã tải về</th><th>Đã sửa đổi</th><th>Tải lên bởi</th><th></th></tr></thead>
+<tbody><tr>
+<td><img src="/Data/SiteImages/Icons/pdf.png"><a title="%s" href="%s/SharedFiles/Download.aspx?pageid=40&amp;mid=118&amp;fileid=%d">BCTC...</a></td>
+<td>%s</td><td>618</td><td>%s</td><td>Admin</td><td></td>
+</tr></tbody></table>
+<span class="PageInfo">Trang %d / %d</span></body></html>`, name, s.URL, 417+page, s.sizeKB, s.modified, page, s.pages)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// downloadCount trả về số lần tệp đã được tải
+func (s *fakeSite) downloadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloads
+}
+
+// newTestCrawler tạo crawler trỏ tới site qua HTTPFetcher dùng client của httptest
+func newTestCrawler(site *fakeSite, documentsDir string, opts ...Option) *Crawler {
+	fetcher := NewHTTPFetcher(site.Client(), utils.RetryPolicy{MaxAttempts: 1}, "NetcoTest/1.0 (+test@example.com)")
+	base := []Option{
+		WithFetcher(fetcher),
+		WithRateLimit(0, 0),
+		WithCategories([]string{testCategory}),
+	}
+	return NewCrawler("", documentsDir, site.URL, append(base, opts...)...)
+}
+
+// crawl chạy thu thập danh sách rồi tải tài liệu
+func crawl(t *testing.T, c *Crawler) {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := c.ProcessHTMLFilesContext(ctx); err != nil {
+		t.Fatalf("ProcessHTMLFilesContext: %v", err)
+	}
+	if err := c.DownloadDocumentsContext(ctx); err != nil {
+		t.Fatalf("DownloadDocumentsContext: %v", err)
+	}
+}
+
+func TestCrawlerWithFetcher(t *testing.T) {
+	site := newFakeSite(t)
+	dir := t.TempDir()
+
+	c := newTestCrawler(site, dir)
+	crawl(t, c)
+
+	docs := c.GetAllDocuments()
+	if len(docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(docs))
+	}
+	doc := docs[0]
+	if doc.Name != "BCTC 2024.pdf" || doc.FileID != 418 || doc.SizeBytes != 3*1024 || doc.DownloadCount != 618 {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if doc.DownloadError != "" {
+		t.Errorf("DownloadError = %q", doc.DownloadError)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, doc.FilePath))
+	if err != nil {
+		t.Fatalf("downloaded file: %v", err)
+	}
+	if string(data) != site.body {
+		t.Errorf("downloaded %d bytes, want %d", len(data), len(site.body))
+	}
+	if doc.SHA256 == "" || doc.FileSize != int64(len(site.body)) || doc.MIMEType != "application/pdf" {
+		t.Errorf("file metadata not recorded: sha256=%q size=%d mime=%q", doc.SHA256, doc.FileSize, doc.MIMEType)
+	}
+
+	for _, ua := range site.userAgents {
+		if ua != "NetcoTest/1.0 (+test@example.com)" {
+			t.Errorf("request sent User-Agent %q", ua)
+		}
+	}
+	if got := site.downloadCount(); got != 1 {
+		t.Errorf("file downloaded %d times, want 1", got)
+	}
+}
+
+// openTestDatabase mở cơ sở dữ liệu tạm cho kiểm thử
+func openTestDatabase(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "netco.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// crawlRun chạy một lần thu thập đầy đủ ghi vào db như cmd/crawler và trả về crawler đã chạy
+func crawlRun(t *testing.T, site *fakeSite, db *database.DB, documentsDir string, opts ...Option) *Crawler {
+	t.Helper()
+
+	ctx := context.Background()
+	run, err := db.StartRun(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestCrawler(site, documentsDir, append(opts, WithDatabase(db, run))...)
+	crawl(t, c)
+	if err := run.SaveAndExport(ctx, c.Result(nil), filepath.Join(t.TempDir(), "data.json")); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCrawlerFindsFileNamedByServer(t *testing.T) {
+	site := newFakeSite(t)
+	site.disposition = `attachment; filename="Server Name.pdf"`
+	db := openTestDatabase(t)
+	dir := t.TempDir()
+
+	for run := 1; run <= 2; run++ {
+		c := crawlRun(t, site, db, dir)
+
+		docs := c.GetAllDocuments()
+		if len(docs) != 1 {
+			t.Fatalf("run %d: got %d documents, want 1", run, len(docs))
+		}
+		doc := docs[0]
+		if filepath.Base(doc.FilePath) != "Server Name.pdf" || doc.ServerFileName != "Server Name.pdf" {
+			t.Errorf("run %d: file_path=%q server_file_name=%q", run, doc.FilePath, doc.ServerFileName)
+		}
+		if doc.SHA256 == "" {
+			t.Errorf("run %d: sha256 not recorded", run)
+		}
+	}
+
+	if got := site.downloadCount(); got != 1 {
+		t.Errorf("file downloaded %d times over two runs, want 1", got)
+	}
+}
+
+func TestCanceledRunKeepsStoredFile(t *testing.T) {
+	site := newFakeSite(t)
+	site.disposition = `attachment; filename="Server Name.pdf"`
+	db := openTestDatabase(t)
+	dir := t.TempDir()
+	first := crawlRun(t, site, db, dir).GetAllDocuments()[0]
+
+	// Tài liệu được tải lên lại nhưng lần chạy thứ hai bị hủy trước khi tải tài liệu nào
+	site.mu.Lock()
+	site.modified = "20/02/2025 09:00:00"
+	site.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	run, err := db.StartRun(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestCrawler(site, dir, WithDatabase(db, run))
+	if err := c.ProcessHTMLFilesContext(ctx); err != nil {
+		t.Fatalf("ProcessHTMLFilesContext: %v", err)
+	}
+	cancel()
+	err = c.DownloadDocumentsContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("DownloadDocumentsContext = %v, want context.Canceled", err)
+	}
+	if err := run.SaveResult(context.Background(), c.Result(err)); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := db.Document(context.Background(), first.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.SHA256 != first.SHA256 || doc.FilePath != first.FilePath || doc.StoredFileName != "Server Name.pdf" {
+		t.Errorf("stored file lost after canceled run: sha256=%q file_path=%q stored_file_name=%q, want %q, %q",
+			doc.SHA256, doc.FilePath, doc.StoredFileName, first.SHA256, first.FilePath)
+	}
+	// Ngày sửa đổi cũ được giữ để lần chạy sau vẫn tải phiên bản mới
+	if doc.Modified != first.Modified {
+		t.Errorf("Modified = %q, want %q of the stored file", doc.Modified, first.Modified)
+	}
+}
+
+func TestChangedDocumentArchivedAfterDownload(t *testing.T) {
+	site := newFakeSite(t)
+	site.disposition = `attachment; filename="Server Name.pdf"`
+	db := openTestDatabase(t)
+	dir := t.TempDir()
+	oldBody := site.body
+	first := crawlRun(t, site, db, dir).GetAllDocuments()[0]
+	oldPath := filepath.Join(dir, first.FilePath)
+
+	// Tài liệu được tải lên lại nhưng lượt tải phiên bản mới thất bại: tệp cũ phải còn nguyên
+	site.mu.Lock()
+	site.modified = "20/02/2025 09:00:00"
+	site.body = "%PDF-1.5 " + strings.Repeat("b", 3000)
+	site.fileStatus = http.StatusServiceUnavailable
+	site.mu.Unlock()
+	crawlRun(t, site, db, dir)
+
+	if data, err := os.ReadFile(oldPath); err != nil || string(data) != oldBody {
+		t.Fatalf("old file changed after failed download: %v", err)
+	}
+	if versions, err := db.VersionFiles(context.Background()); err != nil || len(versions) != 1 || versions[0].SHA256 != first.SHA256 {
+		t.Errorf("VersionFiles after failed download = %+v, %v, want only the current file", versions, err)
+	}
+	// Thay đổi chờ tải ở phiên bản hiện tại, không tạo phiên bản thiếu tệp
+	if versions, err := db.Versions(context.Background(), first.Key()); err != nil || len(versions) != 1 || versions[0].SHA256 != first.SHA256 {
+		t.Errorf("Versions after failed download = %+v, %v, want only version 1", versions, err)
+	}
+	if doc, err := db.Document(context.Background(), first.Key()); err != nil || doc.Version != 1 {
+		t.Errorf("document version after failed download = %+v, %v, want 1", doc, err)
+	}
+	versionPath := filepath.Join(dir, filepath.FromSlash(models.VersionFilePath(first.FilePath, first.SHA256)))
+	if _, err := os.Stat(versionPath); !os.IsNotExist(err) {
+		t.Errorf("version copy left behind after failed download: %v", err)
+	}
+
+	// Lần chạy sau tải được phiên bản mới, phiên bản cũ được lưu giữ
+	site.mu.Lock()
+	site.fileStatus = 0
+	site.mu.Unlock()
+	doc := crawlRun(t, site, db, dir).GetAllDocuments()[0]
+
+	if doc.FilePath != first.FilePath || doc.SHA256 == first.SHA256 {
+		t.Errorf("new version: file_path=%q sha256=%q, want %q with new content", doc.FilePath, doc.SHA256, first.FilePath)
+	}
+	if data, err := os.ReadFile(oldPath); err != nil || string(data) != site.body {
+		t.Errorf("new version not saved at %s: %v", oldPath, err)
+	}
+	if data, err := os.ReadFile(versionPath); err != nil || string(data) != oldBody {
+		t.Errorf("old version not archived at %s: %v", versionPath, err)
+	}
+	versions, err := db.VersionFiles(context.Background())
+	if err != nil || len(versions) != 2 || versions[0].FilePath != models.VersionFilePath(first.FilePath, first.SHA256) {
+		t.Errorf("VersionFiles = %+v, %v, want the archived old version and the new file", versions, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(oldPath))
+	if len(entries) != 1 {
+		t.Errorf("category directory has %d files, want only the new version", len(entries))
+	}
+}
+
+func TestFailedListingPageMarksRunPartial(t *testing.T) {
+	site := newFakeSite(t)
+	db := openTestDatabase(t)
+	dir := t.TempDir()
+	site.pages = 2
+	crawlRun(t, site, db, dir)
+
+	// Trang 2 vẫn lỗi sau khi thử lại: lần chạy không được coi là hoàn tất
+	site.mu.Lock()
+	site.pageStatus = map[int]int{2: http.StatusServiceUnavailable}
+	site.mu.Unlock()
+	c := crawlRun(t, site, db, dir)
+
+	pageErrors := c.PageErrors()
+	if len(pageErrors) != 1 || pageErrors[0].Category != testCategory || pageErrors[0].Page != 2 {
+		t.Fatalf("PageErrors = %v, want page 2 of %s", pageErrors, testCategory)
+	}
+	runs, err := db.Runs(context.Background(), 1)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("Runs = %v, %v", runs, err)
+	}
+	if runs[0].Status != database.RunPartial || !strings.Contains(runs[0].Error, "trang 2") {
+		t.Errorf("run status = %q, error = %q, want %q with the page error", runs[0].Status, runs[0].Error, database.RunPartial)
+	}
+
+	// Tài liệu của trang lỗi không bị xóa khỏi cơ sở dữ liệu
+	docs, err := db.Documents(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(docs[testCategory]); n != 2 {
+		t.Errorf("database has %d documents after the partial run, want 2", n)
+	}
+}
+
+func TestCompletedRunReplacesOnlyCrawledCategories(t *testing.T) {
+	site := newFakeSite(t)
+	db := openTestDatabase(t)
+	dir := t.TempDir()
+	site.pages = 2
+	crawlRun(t, site, db, dir)
+
+	countDocuments := func() int {
+		t.Helper()
+		n, err := db.CountDocuments(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// robots.txt chặn danh mục: lần chạy vẫn hoàn tất nhưng tài liệu đã lưu không bị xóa
+	site.mu.Lock()
+	site.robots = "User-agent: *\nDisallow: /" + testCategory + "\n"
+	site.mu.Unlock()
+	ctx := context.Background()
+	run, err := db.StartRun(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestCrawler(site, dir, WithDatabase(db, run))
+	if err := c.ProcessHTMLFilesContext(ctx); err != nil {
+		t.Fatalf("ProcessHTMLFilesContext: %v", err)
+	}
+	if err := run.SaveResult(ctx, c.Result(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != database.RunCompleted {
+		t.Errorf("robots-blocked run status = %q, want %q", run.Status, database.RunCompleted)
+	}
+	if n := countDocuments(); n != 2 {
+		t.Errorf("database has %d documents after a robots-blocked run, want 2", n)
+	}
+
+	// Danh mục thu thập đủ mọi trang được thay thế: tài liệu không còn trên site bị xóa
+	site.mu.Lock()
+	site.robots = ""
+	site.pages = 1
+	site.mu.Unlock()
+	crawlRun(t, site, db, dir)
+	if n := countDocuments(); n != 1 {
+		t.Errorf("database has %d documents after the category shrank, want 1", n)
+	}
+}
+
+func TestChangedContentWithSameListing(t *testing.T) {
+	site := newFakeSite(t)
+	db := openTestDatabase(t)
+	dir := t.TempDir()
+	oldBody := site.body
+	first := crawlRun(t, site, db, dir).GetAllDocuments()[0]
+	if first.ETag == "" {
+		t.Fatalf("ETag not recorded: %+v", first)
+	}
+
+	// Nội dung chưa đổi: máy chủ trả 304 nên tệp không được tải lại
+	crawlRun(t, site, db, dir)
+	if got := site.downloadCount(); got != 1 {
+		t.Fatalf("unchanged file downloaded %d times, want 1", got)
+	}
+
+	// Tệp bị thay nội dung cùng kích thước, danh sách giữ nguyên ngày sửa đổi
+	site.mu.Lock()
+	site.body = "%PDF-1.5 " + strings.Repeat("b", 3000)
+	site.mu.Unlock()
+	doc := crawlRun(t, site, db, dir).GetAllDocuments()[0]
+
+	if got := site.downloadCount(); got != 2 {
+		t.Errorf("changed file downloaded %d times in total, want 2", got)
+	}
+	stored, err := db.Document(context.Background(), first.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SHA256 == first.SHA256 || stored.Version != 2 {
+		t.Errorf("new content not recorded: sha256=%q version=%d", stored.SHA256, stored.Version)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, doc.FilePath)); err != nil || string(data) != site.body {
+		t.Errorf("new content not saved: %v", err)
+	}
+	versionPath := filepath.Join(dir, filepath.FromSlash(models.VersionFilePath(first.FilePath, first.SHA256)))
+	if data, err := os.ReadFile(versionPath); err != nil || string(data) != oldBody {
+		t.Errorf("old version not archived at %s: %v", versionPath, err)
+	}
+}
@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/netco-crawler/internal/database"
+	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/utils"
+)
+
+// WithDatabase dùng cơ sở dữ liệu siêu dữ liệu: chế độ tăng dần nạp tài liệu cũ từ db
+// thay vì data.json, và mỗi lần tải tệp được ghi vào lần thu thập run (run có thể nil)
+func WithDatabase(db *database.DB, run *database.Run) Option {
+	return func(c *Crawler) {
+		c.db = db
+		c.run = run
+	}
+}
+
+// previousFromDatabase nạp tài liệu của các lần chạy trước từ cơ sở dữ liệu.
+// ok = false nếu không dùng cơ sở dữ liệu hoặc cơ sở dữ liệu chưa có tài liệu
+func (c *Crawler) previousFromDatabase(ctx context.Context) (map[string][]models.Document, bool) {
+	if c.db == nil {
+		return nil, false
+	}
+	docs, err := c.db.Documents(ctx)
+	if err != nil {
+		log.Printf("Không thể nạp dữ liệu cũ từ cơ sở dữ liệu: %v", err)
+		return nil, false
+	}
+	return docs, len(docs) > 0
+}
+
+// recordAttempt ghi một lần tải tệp của tài liệu vào cơ sở dữ liệu (nếu dùng)
+func (c *Crawler) recordAttempt(ctx context.Context, doc models.Document, started time.Time, file *utils.DownloadResult, err error) {
+	if c.run == nil {
+		return
+	}
+
+	attempt := database.Attempt{
+		DocumentID: doc.Key(),
+		URL:        doc.DownloadURL,
+		StartedAt:  started,
+		Duration:   time.Since(started),
+	}
+	if file != nil {
+		attempt.Bytes = file.Size
+		attempt.SHA256 = file.SHA256
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	// Vẫn ghi lần tải bị hủy giữa chừng nên không dùng ctx đã hủy
+	if err := c.run.RecordAttempt(context.WithoutCancel(ctx), attempt); err != nil {
+		log.Printf("Lỗi khi ghi lần tải vào cơ sở dữ liệu: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/netco-crawler/internal/models"
+)
+
+// DefaultDiscoverySeed là trang gốc mặc định để phát hiện danh mục (mục "Quan hệ cổ đông")
+const DefaultDiscoverySeed = "/quan-he-co-dong"
+
+// discoverySelectors là các vùng liên kết trên trang gốc được dùng để tìm danh mục:
+// menu bên trái của mục hiện tại và breadcrumb
+var discoverySelectors = []string{"ul.left-m a", ".breadcrum a"}
+
+// Category là một danh mục tìm thấy khi phát hiện tự động
+type Category struct {
+	Key  string // đường dẫn của trang, ví dụ "bao-cao-tai-chinh"
+	Name string // tên hiển thị tiếng Việt trong menu
+	URL  string
+}
+
+// WithDiscovery bật phát hiện danh mục tự động từ menu và breadcrumb của trang seed
+// (đường dẫn tương đối với baseURL, rỗng để tắt)
+func WithDiscovery(seed string) Option {
+	return func(c *Crawler) {
+		c.discoverySeed = seed
+	}
+}
+
+// WithCategoryFilter chỉ thu thập các danh mục khớp allow (rỗng = tất cả) và không khớp deny.
+// Mẫu theo cú pháp path.Match, ví dụ "bao-cao-*"
+func WithCategoryFilter(allow, deny []string) Option {
+	return func(c *Crawler) {
+		c.categoryAllow = append([]string(nil), allow...)
+		c.categoryDeny = append([]string(nil), deny...)
+	}
+}
+
+// matchAny cho biết category có khớp mẫu nào trong patterns không
+func matchAny(patterns []string, category string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, category); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryAllowed áp dụng danh sách cho phép và danh sách chặn cho một danh mục
+func (c *Crawler) categoryAllowed(category string) bool {
+	if len(c.categoryAllow) > 0 && !matchAny(c.categoryAllow, category) {
+		return false
+	}
+	return !matchAny(c.categoryDeny, category)
+}
+
+// DiscoverCategories đọc menu và breadcrumb của trang seed, tải từng trang được liên kết
+// và trả về những trang có module SharedFiles (bảng danh sách tài liệu)
+func (c *Crawler) DiscoverCategories(ctx context.Context) ([]Category, error) {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("baseURL không hợp lệ: %w", err)
+	}
+	seedURL := base.ResolveReference(&url.URL{Path: c.discoverySeed})
+
+	seed, err := c.fetchDocument(ctx, seedURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("không thể tải trang %s: %w", seedURL, err)
+	}
+
+	// Thu thập các liên kết cùng host trỏ tới trang một cấp, ví dụ /bao-cao-tai-chinh
+	var candidates []Category
+	seen := make(map[string]bool)
+	for _, selector := range discoverySelectors {
+		seed.Find(selector).Each(func(i int, s *goquery.Selection) {
+			href, ok := s.Attr("href")
+			if !ok {
+				return
+			}
+			link, err := seedURL.Parse(href)
+			if err != nil || models.SiteFromHost(link.Host) != models.SiteFromHost(base.Host) {
+				return
+			}
+
+			key := strings.Trim(link.Path, "/")
+			if key == "" || strings.ContainsAny(key, "/.") || seen[key] {
+				return
+			}
+			seen[key] = true
+
+			name := strings.TrimSpace(s.AttrOr("title", ""))
+			if name == "" {
+				name = strings.TrimSpace(s.Text())
+			}
+			candidates = append(candidates, Category{Key: key, Name: name, URL: link.String()})
+		})
+	}
+
+	var found []Category
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return found, err
+		}
+		if !c.categoryAllowed(candidate.Key) || !c.allowedByRobots(candidate.URL) {
+			continue
+		}
+
+		doc, err := c.fetchDocument(ctx, candidate.URL)
+		if err != nil {
+			log.Printf("Không thể kiểm tra trang %s khi phát hiện danh mục: %v", candidate.URL, err)
+			continue
+		}
+		if hostsSharedFiles(doc, c.columnLabels) {
+			found = append(found, candidate)
+		}
+	}
+
+	return found, nil
+}
+
+// hostsSharedFiles cho biết trang có module SharedFiles: có liên kết tải xuống hoặc bảng danh sách tài liệu
+func hostsSharedFiles(doc *goquery.Document, labels ColumnLabels) bool {
+	if doc.Find(`a[href*="SharedFiles/Download.aspx"]`).Length() > 0 {
+		return true
+	}
+	table, _ := findListingTable(doc, labels)
+	return table != nil
+}
+
+// fetchDocument tải và phân tích một trang HTML
+func (c *Crawler) fetchDocument(ctx context.Context, pageURL string) (*goquery.Document, error) {
+	body, err := c.fetcher.FetchPage(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return goquery.NewDocumentFromReader(body)
+}
+
+// prepareCategories bổ sung danh mục phát hiện tự động (nếu bật) và áp dụng danh sách cho phép/chặn
+func (c *Crawler) prepareCategories(ctx context.Context) {
+	if c.categoriesReady {
+		return
+	}
+	c.categoriesReady = true
+
+	if c.discoverySeed != "" {
+		discovered, err := c.DiscoverCategories(ctx)
+		if err != nil {
+			log.Printf("Lỗi khi phát hiện danh mục: %v, tiếp tục với danh sách đã cấu hình", err)
+		}
+
+		existing := make(map[string]bool, len(c.categories))
+		for _, category := range c.categories {
+			existing[category] = true
+		}
+		for _, category := range discovered {
+			if models.RegisterCategory(category.Key, category.Name) {
+				log.Printf("Phát hiện danh mục mới: %s (%s)", category.Key, category.Name)
+			}
+			if !existing[category.Key] {
+				existing[category.Key] = true
+				c.categories = append(c.categories, category.Key)
+			}
+		}
+		log.Printf("Phát hiện %d danh mục có tài liệu từ trang %s", len(discovered), c.discoverySeed)
+	}
+
+	var selected []string
+	for _, category := range c.categories {
+		if c.categoryAllowed(category) {
+			selected = append(selected, category)
+		} else {
+			log.Printf("Bỏ qua danh mục %s theo danh sách cho phép/chặn", category)
+		}
+	}
+	if len(selected) == 0 {
+		log.Println("Không còn danh mục nào để thu thập sau khi áp dụng danh sách cho phép/chặn")
+	}
+	c.categories = selected
+}
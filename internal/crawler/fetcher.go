@@ -0,0 +1,239 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/netco-crawler/internal/utils"
+)
+
+// Fetcher trừu tượng hóa việc tải trang danh sách và tệp tài liệu,
+// cho phép thay thế bằng máy chủ giả (httptest) hoặc transport tùy chỉnh
+type Fetcher interface {
+	// FetchPage tải nội dung một trang HTML, người gọi phải đóng body trả về
+	FetchPage(ctx context.Context, url string) (io.ReadCloser, error)
+
+	// FetchFile tải tệp từ url vào thư mục của destPath. Tên tệp cuối cùng có thể khác destPath
+	// (theo Content-Disposition và kiểu MIME), xem utils.Downloader.Fetch
+	FetchFile(ctx context.Context, url, destPath string) (*utils.DownloadResult, error)
+
+	// FileChanged cho biết tệp tại url đã khác bản có ETag etag và Last-Modified lastModified
+	// đã lưu, xem utils.Downloader.Changed
+	FileChanged(ctx context.Context, url, etag, lastModified string) (bool, error)
+}
+
+// HTTPFetcher là Fetcher mặc định dựa trên http.Client có thể cấu hình,
+// dùng chung một chính sách thử lại cho cả trang danh sách và tệp
+type HTTPFetcher struct {
+	client     *http.Client
+	retry      utils.RetryPolicy
+	userAgent  string
+	downloader *utils.Downloader
+}
+
+// NewHTTPFetcher tạo Fetcher dùng client, chính sách thử lại và User-Agent đã cho,
+// client nil sẽ dùng client mặc định
+func NewHTTPFetcher(client *http.Client, policy utils.RetryPolicy, userAgent string) *HTTPFetcher {
+	if client == nil {
+		client = utils.DefaultHTTPClient()
+	}
+
+	downloader := utils.NewDownloader(client, policy)
+	downloader.UserAgent = userAgent
+
+	return &HTTPFetcher{
+		client:     client,
+		retry:      policy,
+		userAgent:  userAgent,
+		downloader: downloader,
+	}
+}
+
+// FetchPage tải trang HTML và trả về body nếu mã trạng thái là 200.
+// Lỗi tạm thời (mạng, 429, 5xx) sẽ được thử lại theo chính sách
+func (f *HTTPFetcher) FetchPage(ctx context.Context, url string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := utils.Retry(ctx, f.retry, func(attempt int) error {
+		if attempt > 1 {
+			log.Printf("Thử tải lại trang lần %d: %s", attempt, url)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if f.userAgent != "" {
+			req.Header.Set("User-Agent", f.userAgent)
+		}
+
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return utils.NewStatusError(resp)
+		}
+
+		body = resp.Body
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// FetchFile tải tệp bằng client và chính sách thử lại của fetcher
+func (f *HTTPFetcher) FetchFile(ctx context.Context, url, destPath string) (*utils.DownloadResult, error) {
+	return f.downloader.Fetch(ctx, url, destPath)
+}
+
+// FileChanged hỏi máy chủ tệp đã đổi hay chưa bằng client và chính sách thử lại của fetcher
+func (f *HTTPFetcher) FileChanged(ctx context.Context, url, etag, lastModified string) (bool, error) {
+	return f.downloader.Changed(ctx, url, etag, lastModified)
+}
+
+// requestLimits là giới hạn tốc độ và số kết nối mỗi host dùng chung cho mọi yêu cầu của crawler.
+// Các giới hạn có thể bị siết lại khi đang chạy (Crawl-delay của robots.txt)
+type requestLimits struct {
+	mu      sync.Mutex
+	limiter *utils.RateLimiter
+	hosts   *utils.HostLimiter
+}
+
+// acquire chiếm chỗ cho host rồi chờ tới lượt theo giới hạn tốc độ
+func (l *requestLimits) acquire(ctx context.Context, host string) (func(), error) {
+	l.mu.Lock()
+	limiter, hosts := l.limiter, l.hosts
+	l.mu.Unlock()
+
+	release, err := hosts.Acquire(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// limitedTransport áp dụng requestLimits cho từng yêu cầu HTTP. Nhờ nằm dưới vòng thử lại
+// của HTTPFetcher và Downloader, mỗi lần thử đều phải chờ token và thời gian chờ giữa các
+// lần thử (kể cả Retry-After) không giữ chỗ của host
+type limitedTransport struct {
+	next   http.RoundTripper
+	limits *requestLimits
+}
+
+// RoundTrip gửi yêu cầu khi tới lượt, giữ chỗ của host cho đến khi body phản hồi được đóng
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limits.acquire(req.Context(), req.URL.Host)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// limitedClient trả về bản sao của client với transport bị giới hạn bởi limits
+func limitedClient(client *http.Client, limits *requestLimits) *http.Client {
+	if client == nil {
+		client = utils.DefaultHTTPClient()
+	}
+
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	limited := *client
+	limited.Transport = &limitedTransport{next: next, limits: limits}
+	return &limited
+}
+
+// limitedFetcher áp dụng requestLimits cho một Fetcher tùy chỉnh (WithFetcher). Fetcher tùy chỉnh
+// không cho biết từng lần thử bên trong nên giới hạn được áp dụng cho mỗi lần gọi
+type limitedFetcher struct {
+	next   Fetcher
+	limits *requestLimits
+}
+
+// acquire chờ tới lượt và chiếm chỗ cho host của rawURL
+func (f *limitedFetcher) acquire(ctx context.Context, rawURL string) (func(), error) {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+	return f.limits.acquire(ctx, host)
+}
+
+// FetchPage tải trang, giữ chỗ của host cho đến khi body được đóng
+func (f *limitedFetcher) FetchPage(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	release, err := f.acquire(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := f.next.FetchPage(ctx, rawURL)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return &releaseOnClose{ReadCloser: body, release: release}, nil
+}
+
+// FetchFile tải tệp trong khi giữ chỗ của host
+func (f *limitedFetcher) FetchFile(ctx context.Context, rawURL, destPath string) (*utils.DownloadResult, error) {
+	release, err := f.acquire(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return f.next.FetchFile(ctx, rawURL, destPath)
+}
+
+// FileChanged hỏi máy chủ tệp đã đổi hay chưa trong khi giữ chỗ của host
+func (f *limitedFetcher) FileChanged(ctx context.Context, rawURL, etag, lastModified string) (bool, error) {
+	release, err := f.acquire(ctx, rawURL)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	return f.next.FileChanged(ctx, rawURL, etag, lastModified)
+}
+
+// releaseOnClose gọi release đúng một lần khi body được đóng
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,55 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/netco-crawler/internal/utils"
+)
+
+func TestLimitedTransportWaitsPerAttempt(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	// 10 yêu cầu/giây, không có burst: mỗi lần thử sau lần đầu phải chờ khoảng 100ms
+	limits := &requestLimits{limiter: utils.NewRateLimiter(10, 1), hosts: utils.NewHostLimiter(1)}
+	policy := utils.DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	policy.MaxDelay = time.Millisecond
+	policy.Jitter = 0
+	fetcher := NewHTTPFetcher(limitedClient(srv.Client(), limits), policy, "")
+
+	started := time.Now()
+	body, err := fetcher.FetchPage(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("FetchPage: %v", err)
+	}
+	body.Close()
+
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("server got %d requests, want 3", got)
+	}
+	if elapsed := time.Since(started); elapsed < 180*time.Millisecond {
+		t.Errorf("3 attempts took %v, retries bypassed the rate limiter", elapsed)
+	}
+
+	// Chỗ của host đã được trả lại sau khi đóng body
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	release, err := limits.hosts.Acquire(ctx, srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("host slot still held: %v", err)
+	}
+	release()
+}
@@ -0,0 +1,143 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/netco-crawler/internal/models"
+)
+
+// WithIncremental bật chế độ thu thập tăng dần: nạp dữ liệu của lần chạy trước từ previousDataPath,
+// dừng phân trang khi gặp một trang toàn tài liệu đã biết và gộp tài liệu mới vào tập cũ
+func WithIncremental(previousDataPath string) Option {
+	return func(c *Crawler) {
+		c.incrementalPath = previousDataPath
+	}
+}
+
+// loadPreviousDocuments đọc tệp data.json của lần chạy trước
+func loadPreviousDocuments(path string) (map[string][]models.Document, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var docs map[string][]models.Document
+	if err := json.NewDecoder(file).Decode(&docs); err != nil {
+		return nil, fmt.Errorf("không thể decode %s: %w", path, err)
+	}
+
+	return docs, nil
+}
+
+// prepareIncremental nạp dữ liệu cũ (từ cơ sở dữ liệu nếu có, ngược lại từ data.json)
+// và đánh dấu các tài liệu đã biết.
+// Trả về false nếu không có dữ liệu cũ, khi đó crawler thu thập toàn bộ như bình thường
+func (c *Crawler) prepareIncremental(ctx context.Context) bool {
+	if c.incrementalPath == "" {
+		return false
+	}
+	if c.previous != nil {
+		return true
+	}
+
+	source := "cơ sở dữ liệu"
+	previous, ok := c.previousFromDatabase(ctx)
+	if !ok {
+		var err error
+		source = c.incrementalPath
+		previous, err = loadPreviousDocuments(c.incrementalPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				log.Printf("Chưa có dữ liệu cũ tại %s, thu thập toàn bộ", c.incrementalPath)
+			} else {
+				log.Printf("Không thể nạp dữ liệu cũ: %v, thu thập toàn bộ", err)
+			}
+			c.incrementalPath = ""
+			return false
+		}
+	}
+
+	c.previous = previous
+	c.known = make(map[string]models.Document)
+	total := 0
+	for category, docs := range previous {
+		for i := range docs {
+			// Dữ liệu cũ có thể chưa có các trường định danh
+			docs[i].ParseIdentity()
+			docs[i].ParseMetadata()
+			doc := docs[i]
+			c.known[doc.Key()] = doc
+			total++
+		}
+		previous[category] = docs
+	}
+
+	// Giữ lại các danh mục cũ không được thu thập trong lần chạy này
+	c.mu.Lock()
+	for category, docs := range previous {
+		if _, ok := c.documents[category]; !ok {
+			c.documents[category] = docs
+		}
+	}
+	c.mu.Unlock()
+
+	log.Printf("Chế độ tăng dần: đã nạp %d tài liệu từ %s", total, source)
+	return true
+}
+
+// allKnown kiểm tra một trang có toàn bộ tài liệu đã biết và không thay đổi từ lần chạy trước hay không
+func (c *Crawler) allKnown(docs []models.Document) bool {
+	if c.known == nil || len(docs) == 0 {
+		return false
+	}
+	for _, doc := range docs {
+		prev, ok := c.known[doc.Key()]
+		if !ok || doc.ListingChanged(prev) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeWithPrevious gộp tài liệu mới thu thập (đứng trước, mới nhất) với tài liệu cũ của danh mục.
+// Tài liệu đã tải ở lần trước giữ lại thông tin tệp đã lưu (đường dẫn, mã băm...), trừ khi
+// tài liệu đã được tải lên lại và sẽ được tải về thành phiên bản mới
+func (c *Crawler) mergeWithPrevious(category string, docs []models.Document) []models.Document {
+	seen := make(map[string]bool, len(docs))
+	merged := make([]models.Document, 0, len(docs)+len(c.previous[category]))
+
+	stored := make(map[string]models.Document)
+	for _, doc := range c.previous[category] {
+		if doc.StoredFileName != "" {
+			stored[doc.Key()] = doc
+		}
+	}
+
+	newCount := 0
+	for _, doc := range docs {
+		hash := doc.Key()
+		seen[hash] = true
+		if _, ok := c.known[hash]; !ok {
+			newCount++
+		}
+		if prev, ok := stored[hash]; ok && !doc.ListingChanged(prev) {
+			doc.CopyStoredFile(prev)
+		}
+		merged = append(merged, doc)
+	}
+
+	for _, doc := range c.previous[category] {
+		if !seen[doc.Key()] {
+			merged = append(merged, doc)
+		}
+	}
+
+	log.Printf("Danh mục %s: %d tài liệu mới, tổng cộng %d", category, newCount, len(merged))
+	return merged
+}
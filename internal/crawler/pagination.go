@@ -0,0 +1,290 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/netco-crawler/internal/models"
+)
+
+// pageInfoPattern khớp chuỗi "Trang 1 / 14" của span.PageInfo (hoặc "Page 1 of 14" trên trang tiếng Anh)
+var pageInfoPattern = regexp.MustCompile(`(\d+)\s*(?:/|of)\s*(\d+)`)
+
+// parsePageCount trả về số trang từ span.PageInfo, nếu không có thì từ liên kết a.ModulePager.LastPage.
+// Trả về 0 nếu trang không có phân trang
+func parsePageCount(doc *goquery.Document) int {
+	var pages int
+
+	if m := pageInfoPattern.FindStringSubmatch(doc.Find("span.PageInfo").First().Text()); m != nil {
+		fmt.Sscanf(m[2], "%d", &pages)
+		if pages > 0 {
+			return pages
+		}
+	}
+
+	// Trích xuất số trang cuối cùng từ URL của liên kết "Trang cuối"
+	doc.Find("a.ModulePager.LastPage").Each(func(i int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists {
+			return
+		}
+
+		parts := strings.Split(href, "pagenumber=")
+		if len(parts) < 2 {
+			return
+		}
+
+		fmt.Sscanf(parts[1], "%d", &pages)
+	})
+
+	return pages
+}
+
+// localPageCount đọc số trang từ tệp HTML đã lưu của danh mục
+func (c *Crawler) localPageCount(category string) (int, error) {
+	if c.htmlDir == "" {
+		return 0, errors.New("không có thư mục HTML đệm (chế độ chỉ thu thập trực tiếp)")
+	}
+
+	htmlPath := filepath.Join(c.htmlDir, category+".html")
+	file, err := os.Open(htmlPath)
+	if err != nil {
+		return 0, fmt.Errorf("không thể mở tệp HTML %s: %w", htmlPath, err)
+	}
+	defer file.Close()
+
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		return 0, fmt.Errorf("không thể phân tích tệp HTML %s: %w", htmlPath, err)
+	}
+
+	return parsePageCount(doc), nil
+}
+
+// cacheListingPage lưu trang đầu của danh mục vào thư mục HTML đệm
+func (c *Crawler) cacheListingPage(category string, data []byte) {
+	htmlPath := filepath.Join(c.htmlDir, category+".html")
+	tmpPath := htmlPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Printf("Không thể lưu bộ đệm HTML %s: %v", htmlPath, err)
+		return
+	}
+	if err := os.Rename(tmpPath, htmlPath); err != nil {
+		os.Remove(tmpPath)
+		log.Printf("Không thể lưu bộ đệm HTML %s: %v", htmlPath, err)
+	}
+}
+
+// listingPage lấy tài liệu của một trang danh sách từ checkpoint hoặc tải trực tiếp.
+// pages là số trang phát hiện trên trang đó, 0 nếu không xác định được
+func (c *Crawler) listingPage(ctx context.Context, category string, page int) (docs []models.Document, pages int, err error) {
+	// Trang đã xử lý trong lần chạy bị gián đoạn được lấy lại từ checkpoint
+	if docs, ok := c.checkpoint.page(category, page); ok {
+		if page == 1 {
+			pages = c.checkpoint.pageCount(category)
+		}
+		return docs, pages, nil
+	}
+
+	// Xây dựng URL với tham số pagenumber cho tất cả các trang, kể cả trang 1
+	pageURL := fmt.Sprintf("%s/%s?pagenumber=%d", c.baseURL, category, page)
+	if !c.allowedByRobots(pageURL) {
+		return nil, 0, errDisallowedByRobots
+	}
+
+	docs, pages, err = c.fetchListingPage(ctx, pageURL, category, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.checkpoint.recordPage(category, page, pages, docs)
+
+	return docs, pages, nil
+}
+
+// PageError là lỗi của một trang danh sách vẫn thất bại sau khi đã thử lại. Tài liệu của trang
+// không có trong kết quả nên lần chạy không được coi là hoàn tất
+type PageError struct {
+	Category string
+	Page     int
+	Err      error
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("trang %d của danh mục %s: %v", e.Page, e.Category, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// recordPageError ghi log và lưu lại lỗi của một trang danh sách
+func (c *Crawler) recordPageError(category string, page int, err error) {
+	pageErr := &PageError{Category: category, Page: page, Err: err}
+	log.Printf("Lỗi khi xử lý %v", pageErr)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pageErrors = append(c.pageErrors, pageErr)
+}
+
+// PageErrors trả về lỗi của các trang danh sách không tải được sau khi thử lại
+func (c *Crawler) PageErrors() []*PageError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*PageError(nil), c.pageErrors...)
+}
+
+// Incomplete trả về lỗi tổng hợp các trang danh sách không tải được, nil nếu mọi trang đều thành công
+func (c *Crawler) Incomplete() error {
+	var errs []error
+	for _, err := range c.PageErrors() {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// pageResult là kết quả xử lý một trang danh sách
+type pageResult struct {
+	docs []models.Document
+	err  error
+}
+
+// crawlCategory thu thập mọi trang danh sách của một danh mục. Số trang lấy từ trang đầu tải trực tiếp
+// (dự phòng bằng tệp HTML đã lưu) và được cập nhật nếu thay đổi giữa chừng. Các trang còn lại được tải
+// song song trong giới hạn tốc độ của fetcher, kết quả được ghép lại theo thứ tự trang.
+// Trang vẫn lỗi sau khi thử lại được ghi vào PageErrors. complete cho biết mọi trang của danh mục
+// đều đã thu thập được (không có trang lỗi hoặc bị robots.txt chặn), chỉ khi đó danh sách tài liệu
+// mới thay thế được danh sách đã lưu
+func (c *Crawler) crawlCategory(ctx context.Context, category string, incremental bool) (docs []models.Document, complete bool, err error) {
+	log.Printf("Đang xử lý trang 1 của danh mục %s", category)
+	firstDocs, total, err := c.listingPage(ctx, category, 1)
+	if errors.Is(err, errDisallowedByRobots) {
+		log.Printf("robots.txt không cho phép truy cập trang danh sách của danh mục %s, bỏ qua", category)
+		return nil, false, nil
+	}
+
+	if total <= 0 {
+		local, localErr := c.localPageCount(category)
+		switch {
+		case localErr == nil && local > 0:
+			total = local
+		case err != nil && localErr != nil:
+			if ctx.Err() == nil {
+				c.recordPageError(category, 1, err)
+			}
+			log.Printf("Không thể xác định số trang của danh mục %s: %v, bỏ qua danh mục", category, localErr)
+			return nil, false, ctx.Err()
+		default:
+			total = 1
+		}
+	}
+
+	var mu sync.Mutex
+	results := map[int]pageResult{1: {docs: firstDocs, err: err}}
+	stop := total    // Trang cuối cần xử lý, giảm xuống khi gặp trang rỗng hoặc bị chặn
+	blocked := false // robots.txt chặn một trang nên các trang sau không được thu thập
+
+	// finish cập nhật trang dừng, phải gọi khi đang giữ mu
+	finish := func(page int) {
+		if page < stop {
+			stop = page
+		}
+	}
+
+	if err == nil {
+		log.Printf("Tìm thấy %d tài liệu trong danh mục %s (trang 1/%d)", len(firstDocs), category, total)
+		switch {
+		case len(firstDocs) == 0:
+			log.Printf("Trang 1 của danh mục %s không có tài liệu nào, dừng phân trang", category)
+			finish(0)
+		case incremental && c.allKnown(firstDocs):
+			// Danh sách sắp xếp mới nhất trước, trang toàn tài liệu đã biết nghĩa là phần còn lại đã có
+			log.Printf("Trang 1 của danh mục %s chỉ gồm tài liệu đã biết, dừng phân trang", category)
+			finish(1)
+		}
+	}
+
+	next := 2
+	var wg sync.WaitGroup
+	for i := 0; i < c.maxConcurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				mu.Lock()
+				if ctx.Err() != nil || next > total || next > stop {
+					mu.Unlock()
+					return
+				}
+				page := next
+				next++
+				log.Printf("Đang xử lý trang %d/%d của danh mục %s", page, total, category)
+				mu.Unlock()
+
+				docs, pages, err := c.listingPage(ctx, category, page)
+
+				mu.Lock()
+				results[page] = pageResult{docs: docs, err: err}
+				switch {
+				case errors.Is(err, errDisallowedByRobots):
+					log.Printf("robots.txt không cho phép truy cập trang %d của danh mục %s, dừng phân trang", page, category)
+					blocked = true
+					finish(page - 1)
+				case err != nil:
+					log.Printf("Lỗi khi xử lý trang %d của danh mục %s: %v", page, category, err)
+				case len(docs) == 0:
+					log.Printf("Trang %d của danh mục %s không có tài liệu nào, dừng phân trang", page, category)
+					finish(page - 1)
+				default:
+					log.Printf("Tìm thấy %d tài liệu trong danh mục %s (trang %d/%d)", len(docs), category, page, total)
+					if incremental && c.allKnown(docs) {
+						log.Printf("Trang %d của danh mục %s chỉ gồm tài liệu đã biết, dừng phân trang", page, category)
+						finish(page)
+					}
+				}
+
+				// Số trang tăng giữa chừng khi có tài liệu mới được đăng. Chỉ tăng chứ không giảm vì phản hồi
+				// có thể về không theo thứ tự; số trang giảm sẽ được phát hiện qua trang rỗng
+				if pages > total {
+					log.Printf("Số trang của danh mục %s thay đổi từ %d thành %d", category, total, pages)
+					if stop == total {
+						stop = pages
+					}
+					total = pages
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Ghép kết quả theo thứ tự trang, bỏ qua trang lỗi và các trang sau điểm dừng
+	var allCategoryDocs []models.Document
+	complete = !blocked && ctx.Err() == nil
+	for page := 1; page <= stop && page <= total; page++ {
+		result, ok := results[page]
+		switch {
+		case !ok:
+			complete = false
+		case result.err != nil:
+			complete = false
+			if ctx.Err() == nil {
+				c.recordPageError(category, page, result.err)
+			}
+		default:
+			allCategoryDocs = append(allCategoryDocs, result.docs...)
+		}
+	}
+
+	return allCategoryDocs, complete, ctx.Err()
+}
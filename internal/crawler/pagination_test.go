@@ -0,0 +1,62 @@
+package crawler
+
+import "testing"
+
+func TestParsePageCount(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want int
+	}{
+		{
+			name: "page info",
+			html: `<span class="PageInfo">Trang 1 / 14</span>`,
+			want: 14,
+		},
+		{
+			name: "page info without spaces",
+			html: `<span class="PageInfo">Trang 3/15</span>`,
+			want: 15,
+		},
+		{
+			name: "english page info",
+			html: `<span class="PageInfo">Page 2 of 7</span>`,
+			want: 7,
+		},
+		{
+			name: "page info wins over last page link",
+			html: `<span class="PageInfo">Trang 1 / 14</span>
+<a class="ModulePager LastPage" href="/bao-cao-tai-chinh?pagenumber=13">»</a>`,
+			want: 14,
+		},
+		{
+			name: "last page link fallback",
+			html: `<a class="ModulePager LastPage" href="https://netcovn.com.vn/bao-cao-tai-chinh?pagenumber=15">»</a>`,
+			want: 15,
+		},
+		{
+			name: "unreadable page info falls back to last page link",
+			html: `<span class="PageInfo">Trang</span>
+<a class="ModulePager LastPage" href="/cong-bo-thong-tin?pagenumber=9&amp;x=1">»</a>`,
+			want: 9,
+		},
+		{
+			name: "no pagination",
+			html: `<table><tbody><tr><td>A.pdf</td></tr></tbody></table>`,
+			want: 0,
+		},
+		{
+			name: "last page link without page number",
+			html: `<a class="ModulePager LastPage" href="/cong-bo-thong-tin">»</a>`,
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePageCount(parseHTML(t, "<html><body>"+tt.html+"</body></html>")); got != tt.want {
+				t.Errorf("parsePageCount = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
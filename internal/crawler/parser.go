@@ -0,0 +1,230 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/netco-crawler/internal/models"
+)
+
+// Các trường của bảng danh sách tài liệu
+const (
+	ColumnName       = "name"
+	ColumnSize       = "size"
+	ColumnDownloads  = "downloads"
+	ColumnModified   = "modified"
+	ColumnUploadedBy = "uploaded_by"
+)
+
+// expectedColumns là các cột cần có, theo thứ tự mặc định của trang Netco
+var expectedColumns = []string{ColumnName, ColumnSize, ColumnDownloads, ColumnModified, ColumnUploadedBy}
+
+// ColumnLabels ánh xạ mỗi trường tới các nhãn tiêu đề <th> có thể gặp
+type ColumnLabels map[string][]string
+
+// DefaultColumnLabels gồm nhãn của trang tiếng Việt và tiếng Anh
+var DefaultColumnLabels = ColumnLabels{
+	ColumnName:       {"Tên tập tin", "File Name", "Name"},
+	ColumnSize:       {"Kích thước (KB)", "Size (KB)", "Size"},
+	ColumnDownloads:  {"Đã tải về", "Downloads", "Downloaded"},
+	ColumnModified:   {"Đã sửa đổi", "Modified", "Last Modified"},
+	ColumnUploadedBy: {"Tải lên bởi", "Uploaded By", "Uploaded by"},
+}
+
+// ParseWarning là cảnh báo có cấu trúc khi bảng danh sách không như mong đợi
+type ParseWarning struct {
+	Category string `json:"category"`
+	Page     int    `json:"page"`
+	Column   string `json:"column,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (w ParseWarning) String() string {
+	return fmt.Sprintf("category=%s page=%d column=%s message=%q", w.Category, w.Page, w.Column, w.Message)
+}
+
+// WithColumnLabels bổ sung hoặc thay thế nhãn tiêu đề cho từng trường, ví dụ cho trang tiếng Anh
+func WithColumnLabels(labels ColumnLabels) Option {
+	return func(c *Crawler) {
+		merged := make(ColumnLabels, len(c.columnLabels))
+		for field, values := range c.columnLabels {
+			merged[field] = values
+		}
+		for field, values := range labels {
+			merged[field] = append(append([]string(nil), values...), merged[field]...)
+		}
+		c.columnLabels = merged
+	}
+}
+
+// normalizeLabel chuẩn hóa nhãn tiêu đề để so sánh (bỏ khoảng trắng thừa, chữ thường)
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// mapColumns đọc các nhãn <th> của thead và trả về vị trí cột của từng trường
+func mapColumns(thead *goquery.Selection, labels ColumnLabels) map[string]int {
+	lookup := make(map[string]string)
+	for field, values := range labels {
+		for _, value := range values {
+			lookup[normalizeLabel(value)] = field
+		}
+	}
+
+	columns := make(map[string]int)
+	thead.ChildrenFiltered("tr").First().ChildrenFiltered("th, td").Each(func(i int, th *goquery.Selection) {
+		if field, ok := lookup[normalizeLabel(th.Text())]; ok {
+			if _, exists := columns[field]; !exists {
+				columns[field] = i
+			}
+		}
+	})
+
+	return columns
+}
+
+// findListingTable tìm bảng danh sách tài liệu dựa trên tiêu đề cột tên tập tin.
+// Duyệt theo thead để lấy đúng bảng trong cùng, không nhầm với bảng bố cục bao ngoài
+func findListingTable(doc *goquery.Document, labels ColumnLabels) (*goquery.Selection, map[string]int) {
+	var table *goquery.Selection
+	var columns map[string]int
+
+	doc.Find("table > thead").EachWithBreak(func(i int, thead *goquery.Selection) bool {
+		mapped := mapColumns(thead, labels)
+		if _, ok := mapped[ColumnName]; ok {
+			table = thead.Parent()
+			columns = mapped
+			return false
+		}
+		return true
+	})
+
+	return table, columns
+}
+
+// extractDocumentsFromHTML trích xuất tài liệu từ bảng danh sách, ánh xạ ô theo nhãn tiêu đề
+// thay vì vị trí cố định. Trả về cảnh báo khi thiếu cột mong đợi
+func extractDocumentsFromHTML(doc *goquery.Document, category string, labels ColumnLabels, docs *[]models.Document) []ParseWarning {
+	var warnings []ParseWarning
+
+	table, columns := findListingTable(doc, labels)
+	var rows *goquery.Selection
+	if table == nil {
+		// Không nhận diện được tiêu đề: dùng thứ tự cột mặc định của trang Netco
+		if doc.Find("table tbody tr").Length() > 0 {
+			warnings = append(warnings, ParseWarning{
+				Category: category,
+				Message:  "không tìm thấy tiêu đề bảng danh sách, dùng thứ tự cột mặc định",
+			})
+		}
+		columns = make(map[string]int)
+		for i, field := range expectedColumns {
+			columns[field] = i
+		}
+		rows = doc.Find("table tbody tr")
+	} else {
+		for _, field := range expectedColumns {
+			if _, ok := columns[field]; !ok {
+				warnings = append(warnings, ParseWarning{
+					Category: category,
+					Column:   field,
+					Message:  "thiếu cột trong tiêu đề bảng, trường sẽ để trống",
+				})
+			}
+		}
+		rows = table.ChildrenFiltered("tbody").ChildrenFiltered("tr")
+	}
+
+	// cell trả về nội dung ô của trường, rỗng nếu bảng không có cột đó
+	cell := func(cells *goquery.Selection, field string) string {
+		idx, ok := columns[field]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(cells.Eq(idx).Text())
+	}
+
+	rows.Each(func(i int, s *goquery.Selection) {
+		var document models.Document
+		cells := s.ChildrenFiltered("td")
+
+		// Tên và URL tải xuống. Chữ hiển thị thường bị cắt ngắn ("...") và mất phần mở rộng,
+		// còn thuộc tính title giữ tên tệp đầy đủ nên được ưu tiên
+		nameCell := cells.Eq(columns[ColumnName])
+		linkElem := nameCell.Find("a").First()
+		document.DisplayName = strings.TrimSpace(linkElem.Text())
+		if title, ok := linkElem.Attr("title"); ok {
+			document.OriginalFileName = strings.TrimSpace(title)
+		}
+		document.Name = document.OriginalFileName
+		if document.Name == "" {
+			document.Name = document.DisplayName
+		}
+		if document.Name == "" {
+			// Bỏ qua hàng không có tên tài liệu
+			return
+		}
+
+		// Loại tệp khai báo qua biểu tượng, ví dụ /Data/SiteImages/Icons/pdf.png
+		if icon, ok := nameCell.Find("img").First().Attr("src"); ok {
+			document.FileType = models.FileTypeFromIcon(icon)
+		}
+
+		document.DownloadURL, _ = linkElem.Attr("href")
+
+		document.Size = cell(cells, ColumnSize)
+		document.Downloads = cell(cells, ColumnDownloads)
+		document.Modified = cell(cells, ColumnModified)
+		document.UploadedBy = cell(cells, ColumnUploadedBy)
+
+		// Danh mục
+		document.Category = category
+		document.CategoryName = models.CategoryFolder(category)
+
+		// Đường dẫn tệp cục bộ
+		document.FilePath = filepath.Join(
+			models.CategoryFolder(category),
+			document.FileName(),
+		)
+
+		// Định danh ổn định từ fileid của liên kết tải xuống
+		document.ParseIdentity()
+
+		// Phân tích kích thước, lượt tải và ngày sửa đổi thành kiểu dữ liệu tương ứng
+		document.ParseMetadata()
+		if len(document.ParseErrors) > 0 {
+			log.Printf("Không thể phân tích đầy đủ thông tin của %s: %s", document.Name, strings.Join(document.ParseErrors, "; "))
+		}
+
+		*docs = append(*docs, document)
+	})
+
+	return warnings
+}
+
+// recordWarnings ghi log và lưu lại các cảnh báo phân tích của một trang
+func (c *Crawler) recordWarnings(page int, warnings []ParseWarning) {
+	if len(warnings) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, w := range warnings {
+		w.Page = page
+		log.Printf("CẢNH BÁO phân tích bảng: %s", w)
+		c.parseWarnings = append(c.parseWarnings, w)
+	}
+}
+
+// ParseWarnings trả về các cảnh báo phân tích bảng danh sách đã gặp
+func (c *Crawler) ParseWarnings() []ParseWarning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]ParseWarning(nil), c.parseWarnings...)
+}
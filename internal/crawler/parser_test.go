@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/netco-crawler/internal/models"
+)
+
+// parseHTML phân tích đoạn HTML dùng trong kiểm thử
+func parseHTML(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestMapColumns(t *testing.T) {
+	english := WithColumnLabels(ColumnLabels{ColumnSize: {"File size"}})
+	c := &Crawler{columnLabels: DefaultColumnLabels}
+	english(c)
+
+	tests := []struct {
+		name   string
+		header string
+		labels ColumnLabels
+		want   map[string]int
+	}{
+		{
+			name:   "netco order",
+			header: `<tr><th>Tên tập tin</th><th>Kích thước (KB)</th><th>Đã tải về</th><th>Đã sửa đổi</th><th>Tải lên bởi</th><th></th></tr>`,
+			labels: DefaultColumnLabels,
+			want:   map[string]int{ColumnName: 0, ColumnSize: 1, ColumnDownloads: 2, ColumnModified: 3, ColumnUploadedBy: 4},
+		},
+		{
+			name:   "hidden downloads column and reordered",
+			header: `<tr><th>Đã sửa đổi</th><th>Tên tập tin</th><th>Tải lên bởi</th><th>Kích thước (KB)</th></tr>`,
+			labels: DefaultColumnLabels,
+			want:   map[string]int{ColumnModified: 0, ColumnName: 1, ColumnUploadedBy: 2, ColumnSize: 3},
+		},
+		{
+			name: "whitespace, case and td cells",
+			header: `<tr><td>  tên   TẬP tin </td><td>Kích thước
+				(KB)</td><td><span>Đã tải về</span></td></tr>`,
+			labels: DefaultColumnLabels,
+			want:   map[string]int{ColumnName: 0, ColumnSize: 1, ColumnDownloads: 2},
+		},
+		{
+			name:   "english site",
+			header: `<tr><th>File Name</th><th>Size (KB)</th><th>Downloads</th><th>Last Modified</th><th>Uploaded By</th></tr>`,
+			labels: DefaultColumnLabels,
+			want:   map[string]int{ColumnName: 0, ColumnSize: 1, ColumnDownloads: 2, ColumnModified: 3, ColumnUploadedBy: 4},
+		},
+		{
+			name:   "custom label",
+			header: `<tr><th>Name</th><th>File size</th></tr>`,
+			labels: c.columnLabels,
+			want:   map[string]int{ColumnName: 0, ColumnSize: 1},
+		},
+		{
+			name:   "first matching column wins, only first header row",
+			header: `<tr><th>Tên tập tin</th><th>Name</th></tr><tr><th>Đã tải về</th></tr>`,
+			labels: DefaultColumnLabels,
+			want:   map[string]int{ColumnName: 0},
+		},
+		{
+			name:   "unknown labels",
+			header: `<tr><th>Mô tả</th><th>Ghi chú</th></tr>`,
+			labels: DefaultColumnLabels,
+			want:   map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseHTML(t, `<table><thead>`+tt.header+`</thead><tbody></tbody></table>`)
+			got := mapColumns(doc.Find("thead").First(), tt.labels)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapColumns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractDocumentsWarnsOnMissingColumn(t *testing.T) {
+	doc := parseHTML(t, `<table><thead><tr><th>Tên tập tin</th><th>Kích thước (KB)</th><th>Đã sửa đổi</th><th>Tải lên bởi</th></tr></thead>
+<tbody><tr><td><a title="A.pdf" href="https://netcovn.com.vn/SharedFiles/Download.aspx?pageid=40&amp;mid=118&amp;fileid=1">A</a></td>
+<td>10</td><td>17/01/2025 15:08:11</td><td>Admin</td></tr></tbody></table>`)
+
+	var docs []models.Document
+	warnings := extractDocumentsFromHTML(doc, testCategory, DefaultColumnLabels, &docs)
+
+	if len(warnings) != 1 || warnings[0].Column != ColumnDownloads {
+		t.Fatalf("warnings = %v, want one for %s", warnings, ColumnDownloads)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(docs))
+	}
+	if d := docs[0]; d.Size != "10" || d.Downloads != "" || d.Modified != "17/01/2025 15:08:11" || d.UploadedBy != "Admin" {
+		t.Errorf("fields shifted: %+v", d)
+	}
+}
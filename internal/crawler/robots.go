@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/netco-crawler/internal/robots"
+	"github.com/netco-crawler/internal/utils"
+)
+
+// errDisallowedByRobots được ghi lên tài liệu khi robots.txt chặn liên kết tải xuống
+var errDisallowedByRobots = errors.New("bị chặn bởi robots.txt")
+
+// loadRobots tải và phân tích robots.txt của baseURL một lần duy nhất.
+// Theo RFC 9309: 4xx coi như không có luật, lỗi máy chủ hoặc mạng coi như chặn toàn bộ
+func (c *Crawler) loadRobots(ctx context.Context) {
+	if c.ignoreRobots {
+		return
+	}
+
+	c.mu.Lock()
+	loaded := c.robots != nil
+	c.mu.Unlock()
+	if loaded {
+		return
+	}
+
+	robotsURL := strings.TrimRight(c.baseURL, "/") + "/robots.txt"
+	rules := robots.AllowAll()
+
+	body, err := c.fetcher.FetchPage(ctx, robotsURL)
+	if err != nil {
+		var statusErr *utils.StatusError
+		switch {
+		case ctx.Err() != nil:
+			// Bị hủy, lần gọi sau sẽ tải lại
+			return
+		case errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500:
+			log.Printf("Không có robots.txt (%d), cho phép thu thập", statusErr.StatusCode)
+		default:
+			log.Printf("Không thể tải robots.txt (%v), tạm dừng mọi truy cập theo RFC 9309", err)
+			rules = robots.DisallowAll()
+		}
+	} else {
+		parsed, err := robots.Parse(body, c.userAgent)
+		body.Close()
+		if err != nil {
+			log.Printf("Không thể phân tích robots.txt: %v, cho phép thu thập", err)
+		} else {
+			rules = parsed
+		}
+	}
+
+	// Crawl-delay: mỗi lần chỉ một yêu cầu tới máy chủ, các yêu cầu cách nhau ít nhất Crawl-delay
+	// (không có burst). Tốc độ cấu hình chậm hơn vẫn được giữ
+	if rules.CrawlDelay > 0 && !c.ignoreDelay {
+		rps := 1 / rules.CrawlDelay.Seconds()
+		c.limits.mu.Lock()
+		if rate := c.limits.limiter.Rate(); rate > 0 && rate < rps {
+			rps = rate
+		}
+		c.limits.limiter = utils.NewRateLimiter(rps, 1)
+		c.limits.hosts = utils.NewHostLimiter(1)
+		c.limits.mu.Unlock()
+		log.Printf("robots.txt yêu cầu Crawl-delay %v, giới hạn %.2f yêu cầu/giây, một kết nối mỗi host", rules.CrawlDelay, rps)
+	}
+
+	c.mu.Lock()
+	c.robots = rules
+	c.mu.Unlock()
+}
+
+// allowedByRobots kiểm tra URL có được robots.txt cho phép không.
+// Chỉ áp dụng cho URL cùng host với baseURL
+func (c *Crawler) allowedByRobots(rawURL string) bool {
+	if c.ignoreRobots {
+		return true
+	}
+
+	c.mu.Lock()
+	rules := c.robots
+	c.mu.Unlock()
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+	if base, err := url.Parse(c.baseURL); err == nil && target.Host != "" && !strings.EqualFold(target.Host, base.Host) {
+		return true
+	}
+
+	path := target.EscapedPath()
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+
+	return rules.Allowed(path)
+}
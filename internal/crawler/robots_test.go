@@ -0,0 +1,60 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCrawlDelay(t *testing.T) {
+	site := newFakeSite(t)
+	site.robots = "User-agent: *\nCrawl-delay: 0.1\n"
+
+	tests := []struct {
+		name       string
+		ignore     bool
+		wantSpaced bool
+	}{
+		{"live", false, true},
+		{"replay", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCrawler("", t.TempDir(), site.URL,
+				WithHTTPClient(site.Client()), WithRateLimit(0, 0), WithIgnoreCrawlDelay(tt.ignore))
+			ctx := context.Background()
+			c.loadRobots(ctx)
+
+			// Hai yêu cầu liên tiếp ngay sau robots.txt: không được có burst
+			started := time.Now()
+			for i := 0; i < 2; i++ {
+				release, err := c.limits.acquire(ctx, "host")
+				if err != nil {
+					t.Fatal(err)
+				}
+				release()
+			}
+			spaced := time.Since(started) >= 90*time.Millisecond
+			if spaced != tt.wantSpaced {
+				t.Errorf("two requests spaced by Crawl-delay = %v, want %v", spaced, tt.wantSpaced)
+			}
+
+			// Chỉ một kết nối mỗi host khi có Crawl-delay
+			release, err := c.limits.acquire(ctx, "host")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer release()
+			waitCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+			defer cancel()
+			second, err := c.limits.acquire(waitCtx, "host")
+			if err == nil {
+				second()
+			}
+			if blocked := err != nil; blocked != tt.wantSpaced {
+				t.Errorf("second concurrent request blocked = %v, want %v", blocked, tt.wantSpaced)
+			}
+		})
+	}
+}
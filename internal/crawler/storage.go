@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/storage"
+	"github.com/netco-crawler/internal/utils"
+)
+
+// WithStorage đặt kho lưu tài liệu đã tải. Mặc định là thư mục documentsDir; với kho khác
+// (ví dụ S3), documentsDir chỉ là nơi tải tạm và tệp bị xóa sau khi tải lên kho
+func WithStorage(s storage.Storage) Option {
+	return func(c *Crawler) {
+		c.storage = s
+	}
+}
+
+// remoteStorage cho biết kho lưu trữ không phải chính thư mục documentsDir
+func (c *Crawler) remoteStorage() bool {
+	local, ok := c.storage.(*storage.Local)
+	if !ok {
+		return true
+	}
+	a, errA := filepath.Abs(local.Dir())
+	b, errB := filepath.Abs(c.documentsDir)
+	return errA != nil || errB != nil || a != b
+}
+
+// storageKey trả về khóa trong kho của tệp path nằm dưới documentsDir
+func (c *Crawler) storageKey(path string) string {
+	rel, err := filepath.Rel(c.documentsDir, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// storedFile kiểm tra tài liệu đã có trong kho và khớp kích thước trong danh sách hay chưa
+func (c *Crawler) storedFile(ctx context.Context, doc models.Document) (*utils.DownloadResult, bool) {
+	info, err := c.storage.Stat(ctx, filepath.ToSlash(doc.FilePath))
+	if err != nil || !doc.SizeMatches(info.Size) {
+		return nil, false
+	}
+
+	return &utils.DownloadResult{
+		Path:     filepath.Join(c.documentsDir, doc.FilePath),
+		FileName: filepath.Base(doc.FilePath),
+		MIMEType: info.ContentType,
+		Size:     info.Size,
+	}, true
+}
+
+// publish tải tệp vừa tải về lên kho (nếu kho không phải documentsDir) rồi xóa bản tạm trên máy
+func (c *Crawler) publish(ctx context.Context, file *utils.DownloadResult) error {
+	if !c.remoteStorage() {
+		return nil
+	}
+
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := c.storage.Put(ctx, c.storageKey(file.Path), f, file.Size, file.MIMEType); err != nil {
+		return fmt.Errorf("không thể tải %s lên kho lưu trữ: %w", file.FileName, err)
+	}
+	f.Close()
+
+	return os.Remove(file.Path)
+}
@@ -0,0 +1,186 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/netco-crawler/internal/models"
+	"github.com/netco-crawler/internal/storage"
+	"github.com/netco-crawler/internal/utils"
+)
+
+// observe ghi siêu dữ liệu các tài liệu vừa đọc từ trang danh sách vào lần thu thập hiện tại
+func (c *Crawler) observe(ctx context.Context, docs []models.Document) {
+	if c.run == nil || len(docs) == 0 {
+		return
+	}
+	if err := c.run.RecordObservations(context.WithoutCancel(ctx), docs); err != nil {
+		log.Printf("Lỗi khi ghi siêu dữ liệu vào cơ sở dữ liệu: %v", err)
+	}
+}
+
+// loadStoredDocuments nạp trạng thái đã lưu của các tài liệu từ cơ sở dữ liệu (hoặc từ dữ liệu
+// của lần chạy trước ở chế độ tăng dần) để tìm tệp đã tải và phát hiện tài liệu được tải lên lại
+func (c *Crawler) loadStoredDocuments(ctx context.Context) {
+	if c.stored != nil {
+		return
+	}
+
+	docs := c.previous
+	if c.db != nil {
+		var err error
+		if docs, err = c.db.Documents(ctx); err != nil {
+			log.Printf("Không thể nạp tài liệu đã lưu từ cơ sở dữ liệu: %v, không theo dõi phiên bản", err)
+			return
+		}
+	}
+
+	c.stored = make(map[string]models.Document)
+	for _, categoryDocs := range docs {
+		for _, doc := range categoryDocs {
+			c.stored[doc.Key()] = doc
+		}
+	}
+}
+
+// applyStoredFiles giữ lại tệp đã tải ở lần trước cho các tài liệu không thay đổi. Tên tệp đã lưu có thể
+// khác tên theo danh sách (Content-Disposition, kiểu MIME) nên phải lấy từ bản ghi đã lưu
+func (c *Crawler) applyStoredFiles(docs []models.Document) {
+	for i := range docs {
+		prev, ok := c.stored[docs[i].Key()]
+		if ok && prev.StoredFileName != "" && prev.FilePath != "" && !docs[i].ListingChanged(prev) {
+			docs[i].CopyStoredFile(prev)
+		}
+	}
+}
+
+// changedVersion trả về bản đã lưu của tài liệu nếu tệp đã được tải về trước đây nhưng ngày sửa đổi
+// hoặc kích thước trong danh sách nay đã khác, tức tài liệu cần được tải lại thành phiên bản mới
+func (c *Crawler) changedVersion(doc models.Document) (models.Document, bool) {
+	prev, ok := c.stored[doc.Key()]
+	if !ok || prev.SHA256 == "" || prev.FilePath == "" || !doc.ListingChanged(prev) {
+		return models.Document{}, false
+	}
+
+	// Lần chạy bị gián đoạn trước đó đã tải xong phiên bản mới
+	if file := c.checkpoint.downloadedFile(doc.Key()); file != nil && c.checkpoint.downloaded(doc.Key()) && file.SHA256 != prev.SHA256 {
+		return models.Document{}, false
+	}
+	return prev, true
+}
+
+// contentChanged hỏi máy chủ (theo ETag và Last-Modified đã lưu) xem tệp của tài liệu có danh sách không đổi
+// đã bị thay nội dung hay chưa. Trả về bản đã lưu nếu cần tải lại thành phiên bản mới
+func (c *Crawler) contentChanged(ctx context.Context, doc models.Document) (models.Document, bool) {
+	prev, ok := c.stored[doc.Key()]
+	if !ok || prev.SHA256 == "" || prev.FilePath == "" || (prev.ETag == "" && prev.LastModified == "") {
+		return models.Document{}, false
+	}
+
+	changed, err := c.fetcher.FileChanged(ctx, doc.DownloadURL, prev.ETag, prev.LastModified)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Không thể kiểm tra thay đổi của %s: %v", doc.Name, err)
+		}
+		return models.Document{}, false
+	}
+	if !changed {
+		return models.Document{}, false
+	}
+	log.Printf("Tệp của %s đã thay đổi trên máy chủ dù danh sách không đổi", doc.Name)
+	return prev, true
+}
+
+// stageVersion sao tệp của phiên bản cũ sang thư mục phiên bản (models.VersionFilePath) trong kho trước khi
+// tải phiên bản mới. Tệp cũ vẫn được giữ nguyên cho tới khi phiên bản mới tải xong (xem finishVersion).
+// Trả về khóa của bản sao, rỗng nếu tệp không còn trong kho hoặc đã bị thay bằng nội dung khác
+func (c *Crawler) stageVersion(ctx context.Context, prev models.Document) (string, error) {
+	key := filepath.ToSlash(prev.FilePath)
+	versionKey := models.VersionFilePath(key, prev.SHA256)
+
+	body, info, err := c.storage.Get(ctx, key)
+	switch {
+	case errors.Is(err, storage.ErrNotExist):
+		log.Printf("Không còn tệp %s của phiên bản cũ để lưu giữ", key)
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+	defer body.Close()
+
+	// Tệp đã bị thay bằng nội dung khác (ví dụ tải dở) thì không phải phiên bản cũ
+	if prev.FileSize > 0 && info.Size != prev.FileSize {
+		log.Printf("Tệp %s có %d byte, khác phiên bản cũ (%d byte), không lưu giữ", key, info.Size, prev.FileSize)
+		return "", nil
+	}
+	if err := c.storage.Put(ctx, versionKey, body, info.Size, prev.MIMEType); err != nil {
+		return "", fmt.Errorf("không thể lưu giữ phiên bản cũ của %s: %w", prev.Name, err)
+	}
+	return versionKey, nil
+}
+
+// discardVersion xóa bản sao của phiên bản cũ khi phiên bản mới không tải được. Tệp cũ vẫn ở
+// đường dẫn đã lưu nên lần chạy sau sẽ lưu giữ lại
+func (c *Crawler) discardVersion(versionKey string) {
+	if versionKey == "" {
+		return
+	}
+	if err := c.storage.Delete(context.Background(), versionKey); err != nil {
+		log.Printf("Lỗi khi xóa bản lưu giữ %s: %v", versionKey, err)
+	}
+}
+
+// finishVersion ghi nhận bản lưu giữ của phiên bản cũ sau khi phiên bản mới file đã tải xong, rồi
+// xóa tệp cũ nếu phiên bản mới được lưu ở đường dẫn khác. Nếu tệp cũ mang tên của máy chủ khiến
+// phiên bản mới phải lưu theo tên riêng của tài liệu, phiên bản mới được đổi sang tên đó
+func (c *Crawler) finishVersion(ctx context.Context, prev models.Document, versionKey string, file *utils.DownloadResult) {
+	// Tệp mới đã tải xong nên vẫn ghi nhận dù lần chạy vừa bị hủy
+	ctx = context.WithoutCancel(ctx)
+
+	if versionKey != "" {
+		if c.db != nil {
+			if err := c.db.SetVersionFile(ctx, prev.Key(), prev.SHA256, versionKey); err != nil {
+				log.Printf("Lỗi khi ghi phiên bản cũ vào cơ sở dữ liệu: %v", err)
+			}
+		}
+		// Bản lưu giữ trên máy dùng chung nội dung với kho tệp nếu có
+		if !c.remoteStorage() {
+			c.storeBlob(&utils.DownloadResult{Path: filepath.Join(c.documentsDir, filepath.FromSlash(versionKey)), SHA256: prev.SHA256})
+		}
+		log.Printf("Đã lưu giữ phiên bản cũ của %s tại %s", prev.Name, versionKey)
+	}
+
+	oldPath := filepath.Join(c.documentsDir, prev.FilePath)
+	if oldPath == file.Path {
+		// Phiên bản mới đã thay tệp cũ
+		return
+	}
+
+	// Xóa tệp cũ (tệp, liên kết tới kho tệp hoặc đối tượng trong kho từ xa)
+	if c.remoteStorage() {
+		if err := c.storage.Delete(ctx, filepath.ToSlash(prev.FilePath)); err != nil {
+			log.Printf("Lỗi khi xóa tệp cũ %s: %v", prev.FilePath, err)
+		}
+		return
+	}
+	if err := os.Remove(oldPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Lỗi khi xóa tệp cũ %s: %v", oldPath, err)
+		return
+	}
+
+	serverName := utils.FixExtension(file.ServerFileName, file.MIMEType)
+	if file.ServerFileName == "" || filepath.Dir(oldPath) != filepath.Dir(file.Path) || filepath.Base(oldPath) != serverName {
+		return
+	}
+	if err := os.Rename(file.Path, oldPath); err != nil {
+		log.Printf("Lỗi khi đổi tên %s thành %s: %v", file.Path, serverName, err)
+		return
+	}
+	file.Path = oldPath
+	file.FileName = serverName
+}
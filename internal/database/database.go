@@ -0,0 +1,187 @@
+// Package database lưu siêu dữ liệu của crawler trong SQLite (driver thuần Go, không cần CGO):
+// tài liệu, các lần thu thập, các lần tải tệp, tệp theo nội dung (SHA-256) và lịch sử phiên bản
+// của từng tài liệu.
+//
+// Cơ sở dữ liệu thay cho việc ghi lại toàn bộ data.json sau mỗi lần chạy và đọc lại tệp đó
+// ở mỗi yêu cầu HTTP. data.json vẫn được xuất ra (ExportJSON) để tương thích với công cụ cũ
+// và có thể nhập vào cơ sở dữ liệu bằng ImportJSON.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DB là cơ sở dữ liệu siêu dữ liệu
+type DB struct {
+	sql  *sql.DB
+	path string
+}
+
+// migrations là các bước tạo và nâng cấp lược đồ, chỉ số phần tử + 1 là PRAGMA user_version
+var migrations = []string{
+	`CREATE TABLE crawl_runs (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at  TEXT NOT NULL,
+		finished_at TEXT,
+		status      TEXT NOT NULL,
+		documents   INTEGER NOT NULL DEFAULT 0,
+		error       TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE documents (
+		id             TEXT PRIMARY KEY,
+		category       TEXT NOT NULL,
+		position       INTEGER NOT NULL DEFAULT 0,
+		name           TEXT NOT NULL,
+		download_url   TEXT NOT NULL,
+		file_path      TEXT NOT NULL DEFAULT '',
+		sha256         TEXT NOT NULL DEFAULT '',
+		size_bytes     INTEGER NOT NULL DEFAULT 0,
+		download_count INTEGER NOT NULL DEFAULT 0,
+		modified_at    TEXT,
+		download_error TEXT NOT NULL DEFAULT '',
+		first_run_id   INTEGER REFERENCES crawl_runs(id),
+		last_run_id    INTEGER REFERENCES crawl_runs(id),
+		updated_at     TEXT NOT NULL,
+		data           TEXT NOT NULL
+	);
+	CREATE INDEX documents_category ON documents(category, position);
+	CREATE INDEX documents_sha256 ON documents(sha256);
+
+	CREATE TABLE download_attempts (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id      INTEGER NOT NULL REFERENCES crawl_runs(id),
+		document_id TEXT NOT NULL,
+		url         TEXT NOT NULL,
+		started_at  TEXT NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		bytes       INTEGER NOT NULL DEFAULT 0,
+		sha256      TEXT NOT NULL DEFAULT '',
+		error       TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX download_attempts_document ON download_attempts(document_id, started_at);
+
+	CREATE TABLE file_blobs (
+		sha256        TEXT PRIMARY KEY,
+		size          INTEGER NOT NULL,
+		mime_type     TEXT NOT NULL DEFAULT '',
+		first_seen_at TEXT NOT NULL
+	);`,
+
+	`CREATE TABLE document_versions (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		document_id  TEXT NOT NULL,
+		version      INTEGER NOT NULL,
+		first_run_id INTEGER REFERENCES crawl_runs(id),
+		last_run_id  INTEGER REFERENCES crawl_runs(id),
+		created_at   TEXT NOT NULL,
+		modified     TEXT NOT NULL DEFAULT '',
+		modified_at  TEXT,
+		size_bytes   INTEGER NOT NULL DEFAULT 0,
+		sha256       TEXT NOT NULL DEFAULT '',
+		file_size    INTEGER NOT NULL DEFAULT 0,
+		file_path    TEXT NOT NULL DEFAULT '',
+		mime_type    TEXT NOT NULL DEFAULT '',
+		UNIQUE (document_id, version)
+	);
+
+	CREATE TABLE document_observations (
+		run_id         INTEGER NOT NULL REFERENCES crawl_runs(id),
+		document_id    TEXT NOT NULL,
+		observed_at    TEXT NOT NULL,
+		name           TEXT NOT NULL,
+		size           TEXT NOT NULL DEFAULT '',
+		downloads      TEXT NOT NULL DEFAULT '',
+		modified       TEXT NOT NULL DEFAULT '',
+		size_bytes     INTEGER NOT NULL DEFAULT 0,
+		download_count INTEGER NOT NULL DEFAULT 0,
+		modified_at    TEXT,
+		PRIMARY KEY (run_id, document_id)
+	);
+	CREATE INDEX document_observations_document ON document_observations(document_id, run_id);`,
+}
+
+// Open mở (hoặc tạo) cơ sở dữ liệu tại path và nâng cấp lược đồ nếu cần
+func Open(path string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("không thể tạo thư mục cơ sở dữ liệu: %w", err)
+	}
+
+	// WAL cho phép web server đọc trong khi crawler đang ghi
+	dsn := path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_pragma=synchronous(NORMAL)"
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("không thể mở cơ sở dữ liệu %s: %w", path, err)
+	}
+	// SQLite chỉ cho một tiến trình ghi tại một thời điểm, dùng một kết nối để các goroutine
+	// tải tệp không tranh nhau khóa ghi
+	conn.SetMaxOpenConns(1)
+
+	db := &DB{sql: conn, path: path}
+	if err := db.migrate(context.Background()); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("không thể nâng cấp cơ sở dữ liệu %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Path trả về đường dẫn tệp cơ sở dữ liệu
+func (db *DB) Path() string {
+	return db.path
+}
+
+// Close đóng cơ sở dữ liệu
+func (db *DB) Close() error {
+	return db.sql.Close()
+}
+
+// migrate áp dụng các bước trong migrations chưa chạy
+func (db *DB) migrate(ctx context.Context) error {
+	var version int
+	if err := db.sql.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.sql.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("bước %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeLayout là RFC 3339 (UTC) với số chữ số cố định để so sánh được theo thứ tự chuỗi
+const timeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+// formatTime đổi thời điểm sang chuỗi lưu trong cơ sở dữ liệu
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// parseTime đọc thời điểm do formatTime ghi, trả về thời điểm rỗng nếu không hợp lệ
+func parseTime(s sql.NullString) time.Time {
+	if !s.Valid {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339Nano, s.String)
+	return t
+}
@@ -0,0 +1,296 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netco-crawler/internal/models"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "crawler.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func startRun(t *testing.T, db *DB) *Run {
+	t.Helper()
+	run, err := db.StartRun(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+// testDoc tạo tài liệu có ID cố định trong danh mục category
+func testDoc(id, category string) models.Document {
+	return models.Document{
+		ID:          id,
+		Name:        id + ".pdf",
+		DownloadURL: "https://netcovn.com.vn/SharedFiles/Download.aspx?fileid=" + id,
+		Category:    category,
+	}
+}
+
+// downloaded gán thông tin tệp đã tải cho doc
+func downloaded(doc models.Document, sum string) models.Document {
+	doc.FilePath = filepath.Join(doc.Category, doc.Name)
+	doc.SHA256 = sum
+	doc.FileSize = 1024
+	doc.MIMEType = "application/pdf"
+	return doc
+}
+
+func documentIDs(t *testing.T, db *DB) map[string]string {
+	t.Helper()
+	docs, err := db.Documents(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]string)
+	for category, categoryDocs := range docs {
+		for _, doc := range categoryDocs {
+			ids[doc.ID] = category
+		}
+	}
+	return ids
+}
+
+func TestSaveAndReplaceDocuments(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	first := startRun(t, db)
+	err := db.SaveDocuments(ctx, first.ID, map[string][]models.Document{
+		"bao-cao-tai-chinh": {testDoc("a1", "bao-cao-tai-chinh"), testDoc("a2", "bao-cao-tai-chinh")},
+		"cong-bo-thong-tin": {testDoc("b1", "cong-bo-thong-tin")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SaveDocuments chỉ thêm và cập nhật, tài liệu không có trong docs được giữ nguyên
+	second := startRun(t, db)
+	err = db.SaveDocuments(ctx, second.ID, map[string][]models.Document{
+		"bao-cao-tai-chinh": {testDoc("a3", "bao-cao-tai-chinh")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := documentIDs(t, db); len(got) != 4 {
+		t.Fatalf("after SaveDocuments: %v, want a1 a2 a3 b1", got)
+	}
+
+	// ReplaceDocuments chỉ xóa tài liệu của danh mục được thay thế
+	third := startRun(t, db)
+	err = db.ReplaceDocuments(ctx, third.ID, map[string][]models.Document{
+		"bao-cao-tai-chinh": {testDoc("a1", "bao-cao-tai-chinh")},
+		"cong-bo-thong-tin": {},
+	}, []string{"bao-cao-tai-chinh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a1": "bao-cao-tai-chinh", "b1": "cong-bo-thong-tin"}
+	got := documentIDs(t, db)
+	if len(got) != len(want) {
+		t.Fatalf("after ReplaceDocuments: %v, want %v", got, want)
+	}
+	for id, category := range want {
+		if got[id] != category {
+			t.Errorf("document %s in %q, want %q", id, got[id], category)
+		}
+	}
+
+	if _, err := db.Document(ctx, "a2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Document(a2) error = %v, want ErrNotFound", err)
+	}
+	if n, err := db.CountDocuments(ctx); err != nil || n != 2 {
+		t.Errorf("CountDocuments() = %d, %v, want 2", n, err)
+	}
+}
+
+func TestSaveDocumentsKeepsStoredFile(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	first := startRun(t, db)
+	doc := downloaded(testDoc("a1", "bao-cao-tai-chinh"), "sum-1")
+	if err := db.SaveDocuments(ctx, first.ID, map[string][]models.Document{"bao-cao-tai-chinh": {doc}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lần chạy bị hủy trước khi tải lại tài liệu không làm mất thông tin tệp đã lưu
+	second := startRun(t, db)
+	if err := db.SaveDocuments(ctx, second.ID, map[string][]models.Document{
+		"bao-cao-tai-chinh": {testDoc("a1", "bao-cao-tai-chinh")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := db.Document(ctx, "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SHA256 != "sum-1" || stored.FilePath != doc.FilePath {
+		t.Errorf("stored file = %s %q, want sum-1 %q", stored.SHA256, stored.FilePath, doc.FilePath)
+	}
+}
+
+func TestRunsAndResultStatus(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	incomplete := errors.New("trang 2 của danh mục bao-cao-tai-chinh: 500")
+
+	tests := []struct {
+		name   string
+		result Result
+		want   string
+	}{
+		{"completed", Result{Complete: []string{"bao-cao-tai-chinh"}}, RunCompleted},
+		{"partial", Result{Incomplete: incomplete}, RunPartial},
+		{"canceled", Result{Canceled: context.Canceled, Incomplete: incomplete}, RunCanceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.result.Documents = map[string][]models.Document{
+				"bao-cao-tai-chinh": {testDoc("a1", "bao-cao-tai-chinh"), testDoc("a2", "bao-cao-tai-chinh")},
+			}
+			run := startRun(t, db)
+			if err := run.SaveResult(ctx, tt.result); err != nil {
+				t.Fatal(err)
+			}
+
+			runs, err := db.Runs(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(runs) != 1 || runs[0].ID != run.ID {
+				t.Fatalf("Runs(1) = %+v, want run %d", runs, run.ID)
+			}
+			got := runs[0]
+			if got.Status != tt.want || got.Documents != 2 || got.FinishedAt.IsZero() {
+				t.Errorf("run = %s %d finished %v, want %s 2", got.Status, got.Documents, got.FinishedAt, tt.want)
+			}
+			if (got.Error != "") != (tt.want != RunCompleted) {
+				t.Errorf("run error = %q", got.Error)
+			}
+		})
+	}
+
+	runs, err := db.Runs(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != len(tests) || runs[0].ID < runs[1].ID {
+		t.Errorf("Runs(10) returned %d runs, want %d newest first", len(runs), len(tests))
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	run := startRun(t, db)
+
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	attempts := []Attempt{
+		{DocumentID: "a1", URL: "https://netcovn.com.vn/a1", StartedAt: start, Duration: 1500 * time.Millisecond, Error: "503"},
+		{DocumentID: "a1", URL: "https://netcovn.com.vn/a1", StartedAt: start.Add(time.Minute), Duration: 2 * time.Second, Bytes: 1024, SHA256: "sum-1"},
+		{DocumentID: "a2", URL: "https://netcovn.com.vn/a2", StartedAt: start, Duration: time.Second},
+	}
+	for _, a := range attempts {
+		if err := run.RecordAttempt(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := db.Attempts(ctx, "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Attempts(a1) returned %d attempts, want 2", len(got))
+	}
+	for i, want := range attempts[:2] {
+		if !got[i].StartedAt.Equal(want.StartedAt) || got[i].Duration != want.Duration ||
+			got[i].Bytes != want.Bytes || got[i].SHA256 != want.SHA256 || got[i].Error != want.Error {
+			t.Errorf("attempt %d = %+v, want %+v", i, got[i], want)
+		}
+	}
+}
+
+func TestRecordVersion(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	modified := time.Date(2025, 3, 1, 0, 0, 0, 0, models.VietnamLocation)
+	reuploaded := modified.AddDate(0, 1, 0)
+
+	save := func(doc models.Document) int {
+		t.Helper()
+		run := startRun(t, db)
+		if err := db.SaveDocuments(ctx, run.ID, map[string][]models.Document{doc.Category: {doc}}); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := db.Document(ctx, doc.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored.Version
+	}
+
+	doc := testDoc("a1", "bao-cao-tai-chinh")
+	doc.ModifiedAt = &modified
+	doc.SizeBytes = 1024
+
+	// Chưa có tệp thì chưa có phiên bản
+	if v := save(doc); v != 0 {
+		t.Errorf("listed only: version %d, want 0", v)
+	}
+	if v := save(downloaded(doc, "sum-1")); v != 1 {
+		t.Errorf("downloaded: version %d, want 1", v)
+	}
+	// Tải lại cùng nội dung không tạo phiên bản mới
+	if v := save(downloaded(doc, "sum-1")); v != 1 {
+		t.Errorf("same content: version %d, want 1", v)
+	}
+
+	// Danh sách đổi nhưng chưa tải được tệp mới: thay đổi chờ ở phiên bản hiện tại
+	changed := doc
+	changed.ModifiedAt = &reuploaded
+	if v := save(changed); v != 1 {
+		t.Errorf("listing changed without file: version %d, want 1", v)
+	}
+	if v := save(downloaded(changed, "sum-2")); v != 2 {
+		t.Errorf("listing changed with file: version %d, want 2", v)
+	}
+
+	// Nội dung đổi dù danh sách giữ nguyên
+	if v := save(downloaded(changed, "sum-3")); v != 3 {
+		t.Errorf("content changed: version %d, want 3", v)
+	}
+
+	versions, err := db.Versions(ctx, "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("Versions(a1) returned %d versions, want 3", len(versions))
+	}
+	for i, sum := range []string{"sum-1", "sum-2", "sum-3"} {
+		if versions[i].Version != i+1 || versions[i].SHA256 != sum {
+			t.Errorf("version %d = %d %s, want %d %s", i, versions[i].Version, versions[i].SHA256, i+1, sum)
+		}
+	}
+	// Phiên bản cũ cùng đường dẫn đã bị ghi đè nên không còn giữ tệp
+	if versions[0].FilePath != "" || versions[1].FilePath != "" || versions[2].FilePath == "" {
+		t.Errorf("version file paths = %q %q %q, want only the latest", versions[0].FilePath, versions[1].FilePath, versions[2].FilePath)
+	}
+	if versions[0].LastRunID != versions[0].FirstRunID+2 {
+		t.Errorf("version 1 seen in runs %d-%d, want three runs", versions[0].FirstRunID, versions[0].LastRunID)
+	}
+}
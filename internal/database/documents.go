@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/netco-crawler/internal/models"
+)
+
+// ErrNotFound là lỗi khi không tìm thấy tài liệu
+var ErrNotFound = errors.New("không tìm thấy tài liệu")
+
+// SaveDocuments ghi (thêm hoặc cập nhật) các tài liệu theo danh mục, đánh dấu chúng được
+// thấy trong lần thu thập runID và ghi phiên bản mới cho tài liệu đã thay đổi.
+// Tài liệu không có trong docs được giữ nguyên
+func (db *DB) SaveDocuments(ctx context.Context, runID int64, docs map[string][]models.Document) error {
+	return db.saveDocuments(ctx, runID, docs, nil)
+}
+
+// ReplaceDocuments giống SaveDocuments nhưng xóa các tài liệu thuộc categories không có trong docs.
+// categories chỉ nên gồm các danh mục đã thu thập đủ mọi trang, tài liệu của danh mục khác được giữ nguyên
+func (db *DB) ReplaceDocuments(ctx context.Context, runID int64, docs map[string][]models.Document, categories []string) error {
+	return db.saveDocuments(ctx, runID, docs, categories)
+}
+
+func (db *DB) saveDocuments(ctx context.Context, runID int64, docs map[string][]models.Document, replace []string) error {
+	tx, err := db.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert, err := tx.PrepareContext(ctx, `INSERT INTO documents
+		(id, category, position, name, download_url, file_path, sha256, size_bytes, download_count,
+		 modified_at, download_error, first_run_id, last_run_id, updated_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			category = excluded.category, position = excluded.position, name = excluded.name,
+			download_url = excluded.download_url, file_path = excluded.file_path, sha256 = excluded.sha256,
+			size_bytes = excluded.size_bytes, download_count = excluded.download_count,
+			modified_at = excluded.modified_at, download_error = excluded.download_error,
+			last_run_id = excluded.last_run_id, updated_at = excluded.updated_at, data = excluded.data`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+
+	blob, err := tx.PrepareContext(ctx,
+		"INSERT INTO file_blobs (sha256, size, mime_type, first_seen_at) VALUES (?, ?, ?, ?) ON CONFLICT(sha256) DO NOTHING")
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	now := formatTime(time.Now())
+	for category, categoryDocs := range docs {
+		for i, doc := range categoryDocs {
+			if doc.ID == "" {
+				doc.ID = doc.Key()
+			}
+			if doc.Category == "" {
+				doc.Category = category
+			}
+			// Giữ tệp đã lưu trước khi ghi phiên bản để tài liệu chưa tải lại được không tạo phiên bản mới
+			if doc.SHA256 == "" {
+				if err := keepStoredFile(ctx, tx, &doc); err != nil {
+					return fmt.Errorf("không thể đọc tài liệu đã lưu %s: %w", doc.ID, err)
+				}
+			}
+			if doc.Version, err = recordVersion(ctx, tx, runID, doc, now); err != nil {
+				return fmt.Errorf("không thể lưu phiên bản của %s: %w", doc.ID, err)
+			}
+			data, err := json.Marshal(doc)
+			if err != nil {
+				return fmt.Errorf("không thể encode tài liệu %s: %w", doc.ID, err)
+			}
+
+			if _, err := upsert.ExecContext(ctx, doc.ID, category, i, doc.Name, doc.DownloadURL, doc.FilePath,
+				doc.SHA256, doc.SizeBytes, doc.DownloadCount, nullTime(doc.ModifiedAt), doc.DownloadError,
+				runID, runID, now, string(data)); err != nil {
+				return fmt.Errorf("không thể lưu tài liệu %s: %w", doc.ID, err)
+			}
+
+			if doc.SHA256 != "" {
+				if _, err := blob.ExecContext(ctx, doc.SHA256, doc.FileSize, doc.MIMEType, now); err != nil {
+					return fmt.Errorf("không thể lưu tệp %s: %w", doc.SHA256, err)
+				}
+			}
+		}
+	}
+
+	for _, category := range replace {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM documents WHERE category = ? AND last_run_id IS NOT ?", category, runID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// keepStoredFile giữ thông tin tệp đã tải (đường dẫn, mã băm...) của bản ghi cũ cho tài liệu chưa có
+// tệp trong lần thu thập này, ví dụ lần chạy bị hủy trước khi tới tài liệu hoặc lượt tải bị lỗi.
+// Nếu danh sách đã đổi (tài liệu được tải lên lại), ngày sửa đổi và kích thước cũ cũng được giữ để
+// bản ghi vẫn mô tả đúng tệp đang có và lần chạy sau vẫn nhận ra phiên bản mới cần tải
+func keepStoredFile(ctx context.Context, tx *sql.Tx, doc *models.Document) error {
+	var data string
+	err := tx.QueryRowContext(ctx, "SELECT data FROM documents WHERE id = ?", doc.ID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var prev models.Document
+	if err := json.Unmarshal([]byte(data), &prev); err != nil {
+		return err
+	}
+	if prev.SHA256 == "" {
+		return nil
+	}
+
+	if doc.ListingChanged(prev) {
+		doc.Modified, doc.ModifiedAt = prev.Modified, prev.ModifiedAt
+		doc.Size, doc.SizeBytes = prev.Size, prev.SizeBytes
+	}
+	doc.CopyStoredFile(prev)
+	return nil
+}
+
+// Documents trả về toàn bộ tài liệu theo danh mục, mỗi danh mục giữ thứ tự khi lưu
+func (db *DB) Documents(ctx context.Context) (map[string][]models.Document, error) {
+	rows, err := db.sql.QueryContext(ctx, "SELECT category, data FROM documents ORDER BY category, position, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := make(map[string][]models.Document)
+	for rows.Next() {
+		var category, data string
+		if err := rows.Scan(&category, &data); err != nil {
+			return nil, err
+		}
+		var doc models.Document
+		if err := json.Unmarshal([]byte(data), &doc); err != nil {
+			return nil, fmt.Errorf("không thể decode tài liệu: %w", err)
+		}
+		docs[category] = append(docs[category], doc)
+	}
+	return docs, rows.Err()
+}
+
+// Document trả về tài liệu theo ID (site:fileid), lỗi ErrNotFound nếu không có
+func (db *DB) Document(ctx context.Context, id string) (*models.Document, error) {
+	var data string
+	err := db.sql.QueryRowContext(ctx, "SELECT data FROM documents WHERE id = ?", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc models.Document
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, fmt.Errorf("không thể decode tài liệu %s: %w", id, err)
+	}
+	return &doc, nil
+}
+
+// CountDocuments trả về số tài liệu trong cơ sở dữ liệu
+func (db *DB) CountDocuments(ctx context.Context) (int, error) {
+	var n int
+	err := db.sql.QueryRowContext(ctx, "SELECT COUNT(*) FROM documents").Scan(&n)
+	return n, err
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/netco-crawler/internal/models"
+)
+
+// ImportJSON nhập tệp data.json (danh mục -> tài liệu) vào cơ sở dữ liệu như một lần chạy
+// có trạng thái "imported". Tài liệu đã có được cập nhật, tài liệu khác được giữ nguyên.
+// Trả về số tài liệu đã nhập
+func (db *DB) ImportJSON(ctx context.Context, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var docs map[string][]models.Document
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return 0, fmt.Errorf("không thể phân tích %s: %w", path, err)
+	}
+
+	// Dữ liệu cũ có thể chưa có các trường định danh và các trường đã phân tích
+	total := 0
+	for _, categoryDocs := range docs {
+		for i := range categoryDocs {
+			categoryDocs[i].ParseIdentity()
+			if categoryDocs[i].ModifiedAt == nil && len(categoryDocs[i].ParseErrors) == 0 {
+				categoryDocs[i].ParseMetadata()
+			}
+		}
+		total += len(categoryDocs)
+	}
+
+	run, err := db.startRun(ctx, RunImported)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.SaveDocuments(ctx, run.ID, docs); err != nil {
+		run.Finish(ctx, RunFailed, 0, err)
+		return 0, err
+	}
+	if err := run.Finish(ctx, RunImported, total, nil); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// ImportJSONIfEmpty nhập data.json khi cơ sở dữ liệu chưa có tài liệu nào, dùng để chuyển
+// dữ liệu của phiên bản cũ sang lần đầu chạy. Không làm gì nếu tệp không tồn tại
+func (db *DB) ImportJSONIfEmpty(ctx context.Context, path string) (int, error) {
+	count, err := db.CountDocuments(ctx)
+	if err != nil || count > 0 {
+		return 0, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return 0, nil
+	}
+	return db.ImportJSON(ctx, path)
+}
+
+// ExportJSON ghi toàn bộ tài liệu ra path theo định dạng data.json cũ
+func (db *DB) ExportJSON(ctx context.Context, path string) error {
+	docs, err := db.Documents(ctx)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(docs, path)
+}
+
+// WriteJSON ghi tài liệu theo danh mục ra path theo định dạng data.json.
+// Tệp được ghi tạm rồi đổi tên để người đọc không thấy tệp dở dang
+func WriteJSON(docs map[string][]models.Document, path string) error {
+	// Đảm bảo thư mục đích tồn tại
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("không thể tạo thư mục đích: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("không thể tạo tệp JSON: %w", err)
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(docs); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("không thể encode dữ liệu thành JSON: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/netco-crawler/internal/models"
+)
+
+// Trạng thái của một lần thu thập
+const (
+	RunRunning   = "running"   // đang chạy hoặc tiến trình bị dừng đột ngột
+	RunCompleted = "completed" // thu thập và tải xuống hoàn tất
+	RunPartial   = "partial"   // chạy hết nhưng có trang danh sách lỗi, chỉ thêm và cập nhật tài liệu
+	RunCanceled  = "canceled"  // bị hủy bằng SIGINT/SIGTERM, dữ liệu thu được vẫn được lưu
+	RunFailed    = "failed"    // dừng vì lỗi
+	RunImported  = "imported"  // dữ liệu nhập từ data.json, không phải lần thu thập thật
+)
+
+// Run là một lần thu thập (hoặc một lần nhập dữ liệu)
+type Run struct {
+	ID         int64     `json:"id"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Status     string    `json:"status"`
+	Documents  int       `json:"documents"`
+	Error      string    `json:"error,omitempty"`
+
+	db *DB
+}
+
+// Attempt là một lần tải tệp của tài liệu
+type Attempt struct {
+	DocumentID string        `json:"document_id"`
+	URL        string        `json:"url"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+	Bytes      int64         `json:"bytes,omitempty"`
+	SHA256     string        `json:"sha256,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// StartRun ghi nhận bắt đầu một lần thu thập mới
+func (db *DB) StartRun(ctx context.Context) (*Run, error) {
+	return db.startRun(ctx, RunRunning)
+}
+
+func (db *DB) startRun(ctx context.Context, status string) (*Run, error) {
+	run := &Run{StartedAt: time.Now(), Status: status, db: db}
+	result, err := db.sql.ExecContext(ctx,
+		"INSERT INTO crawl_runs (started_at, status) VALUES (?, ?)", formatTime(run.StartedAt), status)
+	if err != nil {
+		return nil, fmt.Errorf("không thể ghi lần thu thập: %w", err)
+	}
+	if run.ID, err = result.LastInsertId(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Finish ghi trạng thái kết thúc và số tài liệu của lần thu thập, runErr là lỗi nếu có
+func (r *Run) Finish(ctx context.Context, status string, documents int, runErr error) error {
+	r.FinishedAt = time.Now()
+	r.Status = status
+	r.Documents = documents
+	if runErr != nil {
+		r.Error = runErr.Error()
+	}
+
+	_, err := r.db.sql.ExecContext(ctx,
+		"UPDATE crawl_runs SET finished_at = ?, status = ?, documents = ?, error = ? WHERE id = ?",
+		formatTime(r.FinishedAt), r.Status, r.Documents, r.Error, r.ID)
+	if err != nil {
+		return fmt.Errorf("không thể cập nhật lần thu thập %d: %w", r.ID, err)
+	}
+	return nil
+}
+
+// Result là kết quả của một lần thu thập cần lưu
+type Result struct {
+	Documents  map[string][]models.Document
+	Complete   []string // danh mục đã thu thập đủ mọi trang danh sách
+	Canceled   error    // khác nil nếu lần chạy bị hủy
+	Incomplete error    // khác nil nếu có trang danh sách không tải được
+}
+
+// Status trả về trạng thái kết thúc và lỗi tương ứng của lần chạy
+func (res Result) Status() (string, error) {
+	switch {
+	case res.Canceled != nil:
+		return RunCanceled, errors.Join(res.Canceled, res.Incomplete)
+	case res.Incomplete != nil:
+		return RunPartial, res.Incomplete
+	default:
+		return RunCompleted, nil
+	}
+}
+
+// SaveResult lưu tài liệu thu được và kết thúc lần thu thập với trạng thái theo res.
+// Lần chạy hoàn tất thay thế tài liệu của các danh mục đã thu thập đủ; lần chạy thiếu trang, bị hủy
+// hoặc lỗi chỉ thêm và cập nhật để không mất tài liệu của các danh mục hoặc trang chưa thu thập được
+func (r *Run) SaveResult(ctx context.Context, res Result) error {
+	status, runErr := res.Status()
+	var err error
+	if status == RunCompleted {
+		err = r.db.ReplaceDocuments(ctx, r.ID, res.Documents, res.Complete)
+	} else {
+		err = r.db.SaveDocuments(ctx, r.ID, res.Documents)
+	}
+	if err != nil {
+		r.Finish(ctx, RunFailed, 0, err)
+		return err
+	}
+
+	total := 0
+	for _, categoryDocs := range res.Documents {
+		total += len(categoryDocs)
+	}
+	return r.Finish(ctx, status, total, runErr)
+}
+
+// SaveAndExport lưu kết quả của lần chạy vào cơ sở dữ liệu và xuất lại data.json (dataFile) để tương thích.
+// Kết quả vẫn được lưu dù ctx đã bị hủy
+func (r *Run) SaveAndExport(ctx context.Context, res Result, dataFile string) error {
+	ctx = context.WithoutCancel(ctx)
+	if err := r.SaveResult(ctx, res); err != nil {
+		return err
+	}
+
+	if err := r.db.ExportJSON(ctx, dataFile); err != nil {
+		return fmt.Errorf("không thể xuất %s: %w", dataFile, err)
+	}
+	log.Printf("Đã lưu dữ liệu vào %s và %s", r.db.Path(), dataFile)
+	return nil
+}
+
+// RecordAttempt ghi một lần tải tệp thuộc lần thu thập này
+func (r *Run) RecordAttempt(ctx context.Context, a Attempt) error {
+	_, err := r.db.sql.ExecContext(ctx,
+		`INSERT INTO download_attempts (run_id, document_id, url, started_at, duration_ms, bytes, sha256, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, a.DocumentID, a.URL, formatTime(a.StartedAt), a.Duration.Milliseconds(), a.Bytes, a.SHA256, a.Error)
+	if err != nil {
+		return fmt.Errorf("không thể ghi lần tải %s: %w", a.DocumentID, err)
+	}
+	return nil
+}
+
+// Runs trả về limit lần thu thập gần nhất, mới nhất trước
+func (db *DB) Runs(ctx context.Context, limit int) ([]Run, error) {
+	rows, err := db.sql.QueryContext(ctx,
+		"SELECT id, started_at, finished_at, status, documents, error FROM crawl_runs ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var run Run
+		var started, finished sql.NullString
+		if err := rows.Scan(&run.ID, &started, &finished, &run.Status, &run.Documents, &run.Error); err != nil {
+			return nil, err
+		}
+		run.StartedAt = parseTime(started)
+		run.FinishedAt = parseTime(finished)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Attempts trả về các lần tải tệp của tài liệu id theo thứ tự thời gian
+func (db *DB) Attempts(ctx context.Context, id string) ([]Attempt, error) {
+	rows, err := db.sql.QueryContext(ctx,
+		`SELECT document_id, url, started_at, duration_ms, bytes, sha256, error
+		FROM download_attempts WHERE document_id = ? ORDER BY started_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []Attempt
+	for rows.Next() {
+		var a Attempt
+		var started sql.NullString
+		var durationMS int64
+		if err := rows.Scan(&a.DocumentID, &a.URL, &started, &durationMS, &a.Bytes, &a.SHA256, &a.Error); err != nil {
+			return nil, err
+		}
+		a.StartedAt = parseTime(started)
+		a.Duration = time.Duration(durationMS) * time.Millisecond
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/netco-crawler/internal/models"
+)
+
+// Version là một phiên bản của tài liệu. Phiên bản mới được tạo khi ngày sửa đổi, kích thước
+// trong danh sách hoặc mã băm của tệp thay đổi so với phiên bản trước
+type Version struct {
+	DocumentID string     `json:"document_id"`
+	Version    int        `json:"version"`
+	FirstRunID int64      `json:"first_run_id"` // lần chạy đầu tiên thấy phiên bản này
+	LastRunID  int64      `json:"last_run_id"`  // lần chạy gần nhất thấy phiên bản này
+	CreatedAt  time.Time  `json:"created_at"`
+	Modified   string     `json:"modified"`
+	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+	SizeBytes  int64      `json:"size_bytes"`
+	SHA256     string     `json:"sha256,omitempty"`
+	FileSize   int64      `json:"file_size,omitempty"`
+	FilePath   string     `json:"file_path,omitempty"` // rỗng nếu tệp của phiên bản không còn được giữ
+	MIMEType   string     `json:"mime_type,omitempty"`
+}
+
+// Observation là siêu dữ liệu của tài liệu trên trang danh sách trong một lần thu thập
+type Observation struct {
+	RunID         int64      `json:"run_id"`
+	ObservedAt    time.Time  `json:"observed_at"`
+	Name          string     `json:"name"`
+	Size          string     `json:"size"`
+	Downloads     string     `json:"downloads"`
+	Modified      string     `json:"modified"`
+	SizeBytes     int64      `json:"size_bytes"`
+	DownloadCount int        `json:"download_count"`
+	ModifiedAt    *time.Time `json:"modified_at,omitempty"`
+}
+
+// RecordObservations ghi siêu dữ liệu của các tài liệu vừa đọc từ trang danh sách
+func (r *Run) RecordObservations(ctx context.Context, docs []models.Document) error {
+	tx, err := r.db.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO document_observations
+		(run_id, document_id, observed_at, name, size, downloads, modified, size_bytes, download_count, modified_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(run_id, document_id) DO UPDATE SET
+			observed_at = excluded.observed_at, name = excluded.name, size = excluded.size,
+			downloads = excluded.downloads, modified = excluded.modified, size_bytes = excluded.size_bytes,
+			download_count = excluded.download_count, modified_at = excluded.modified_at`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := formatTime(time.Now())
+	for _, doc := range docs {
+		if _, err := stmt.ExecContext(ctx, r.ID, doc.Key(), now, doc.Name, doc.Size, doc.Downloads, doc.Modified,
+			doc.SizeBytes, doc.DownloadCount, nullTime(doc.ModifiedAt)); err != nil {
+			return fmt.Errorf("không thể ghi siêu dữ liệu của %s: %w", doc.Key(), err)
+		}
+	}
+	return tx.Commit()
+}
+
+// recordVersion so tài liệu với phiên bản mới nhất đã lưu: tạo phiên bản mới nếu tài liệu
+// đã thay đổi, ngược lại bổ sung thông tin tệp vào phiên bản hiện tại. Trả về số phiên bản.
+// Phiên bản mới chỉ được tạo khi đã có mã băm của tệp mới; trước đó thay đổi được giữ chờ
+// ở phiên bản hiện tại (lần chạy sau sẽ tải lại)
+func recordVersion(ctx context.Context, tx *sql.Tx, runID int64, doc models.Document, now string) (int, error) {
+	var (
+		id         int64
+		version    int
+		modifiedAt sql.NullString
+		sizeBytes  int64
+		sum        string
+	)
+	err := tx.QueryRowContext(ctx, `SELECT id, version, modified_at, size_bytes, sha256 FROM document_versions
+		WHERE document_id = ? ORDER BY version DESC LIMIT 1`, doc.ID).Scan(&id, &version, &modifiedAt, &sizeBytes, &sum)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	// Đường dẫn tệp chỉ có ý nghĩa khi tệp đã được tải về
+	filePath := ""
+	if doc.SHA256 != "" {
+		filePath = doc.FilePath
+	}
+
+	changed := version == 0 ||
+		(modifiedAt.Valid && doc.ModifiedAt != nil && formatTime(*doc.ModifiedAt) != modifiedAt.String) ||
+		(sizeBytes > 0 && doc.SizeBytes > 0 && sizeBytes != doc.SizeBytes) ||
+		(sum != "" && doc.SHA256 != "" && sum != doc.SHA256)
+	if changed && doc.SHA256 == "" {
+		if version == 0 {
+			return 0, nil
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE document_versions SET last_run_id = ? WHERE id = ?", runID, id)
+		return version, err
+	}
+	if !changed {
+		_, err := tx.ExecContext(ctx, `UPDATE document_versions SET
+			last_run_id = ?,
+			modified = CASE WHEN ? <> '' THEN ? ELSE modified END,
+			modified_at = COALESCE(?, modified_at),
+			size_bytes = CASE WHEN ? > 0 THEN ? ELSE size_bytes END,
+			sha256 = CASE WHEN ? <> '' THEN ? ELSE sha256 END,
+			file_size = CASE WHEN ? > 0 THEN ? ELSE file_size END,
+			file_path = CASE WHEN ? <> '' THEN ? ELSE file_path END,
+			mime_type = CASE WHEN ? <> '' THEN ? ELSE mime_type END
+			WHERE id = ?`,
+			runID, doc.Modified, doc.Modified, nullTime(doc.ModifiedAt), doc.SizeBytes, doc.SizeBytes,
+			doc.SHA256, doc.SHA256, doc.FileSize, doc.FileSize, filePath, filePath, doc.MIMEType, doc.MIMEType, id)
+		return version, err
+	}
+
+	// Tệp của phiên bản cũ nằm cùng đường dẫn đã bị nội dung mới ghi đè
+	// (phiên bản được lưu giữ có đường dẫn riêng, xem SetVersionFile)
+	if filePath != "" {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE document_versions SET file_path = '' WHERE document_id = ? AND file_path = ?", doc.ID, filePath); err != nil {
+			return 0, err
+		}
+	}
+
+	version++
+	_, err = tx.ExecContext(ctx, `INSERT INTO document_versions
+		(document_id, version, first_run_id, last_run_id, created_at, modified, modified_at, size_bytes,
+		 sha256, file_size, file_path, mime_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		doc.ID, version, runID, runID, now, doc.Modified, nullTime(doc.ModifiedAt), doc.SizeBytes,
+		doc.SHA256, doc.FileSize, filePath, doc.MIMEType)
+	return version, err
+}
+
+// SetVersionFile ghi nơi lưu giữ tệp của phiên bản có mã băm sum khi tài liệu id được tải lại
+func (db *DB) SetVersionFile(ctx context.Context, id, sum, path string) error {
+	_, err := db.sql.ExecContext(ctx,
+		"UPDATE document_versions SET file_path = ? WHERE document_id = ? AND sha256 = ?", path, id, sum)
+	if err != nil {
+		return fmt.Errorf("không thể ghi phiên bản cũ của %s: %w", id, err)
+	}
+	return nil
+}
+
+// Versions trả về các phiên bản của tài liệu id, cũ nhất trước
+func (db *DB) Versions(ctx context.Context, id string) ([]Version, error) {
+	return db.queryVersions(ctx, "WHERE document_id = ? ORDER BY version", id)
+}
+
+// VersionFiles trả về các phiên bản còn giữ tệp của mọi tài liệu
+func (db *DB) VersionFiles(ctx context.Context) ([]Version, error) {
+	return db.queryVersions(ctx, "WHERE file_path <> '' ORDER BY document_id, version")
+}
+
+func (db *DB) queryVersions(ctx context.Context, where string, args ...any) ([]Version, error) {
+	rows, err := db.sql.QueryContext(ctx, `SELECT document_id, version, first_run_id, last_run_id, created_at,
+		modified, modified_at, size_bytes, sha256, file_size, file_path, mime_type
+		FROM document_versions `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []Version
+	for rows.Next() {
+		var v Version
+		var firstRun, lastRun sql.NullInt64
+		var created, modifiedAt sql.NullString
+		if err := rows.Scan(&v.DocumentID, &v.Version, &firstRun, &lastRun, &created, &v.Modified, &modifiedAt,
+			&v.SizeBytes, &v.SHA256, &v.FileSize, &v.FilePath, &v.MIMEType); err != nil {
+			return nil, err
+		}
+		v.FirstRunID = firstRun.Int64
+		v.LastRunID = lastRun.Int64
+		v.CreatedAt = parseTime(created)
+		v.ModifiedAt = parseNullTime(modifiedAt)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// Observations trả về siêu dữ liệu của tài liệu id qua các lần thu thập, cũ nhất trước
+func (db *DB) Observations(ctx context.Context, id string) ([]Observation, error) {
+	rows, err := db.sql.QueryContext(ctx, `SELECT run_id, observed_at, name, size, downloads, modified,
+		size_bytes, download_count, modified_at
+		FROM document_observations WHERE document_id = ? ORDER BY run_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var observations []Observation
+	for rows.Next() {
+		var o Observation
+		var observed, modifiedAt sql.NullString
+		if err := rows.Scan(&o.RunID, &observed, &o.Name, &o.Size, &o.Downloads, &o.Modified,
+			&o.SizeBytes, &o.DownloadCount, &modifiedAt); err != nil {
+			return nil, err
+		}
+		o.ObservedAt = parseTime(observed)
+		o.ModifiedAt = parseNullTime(modifiedAt)
+		observations = append(observations, o)
+	}
+	return observations, rows.Err()
+}
+
+// nullTime đổi thời điểm có thể rỗng sang giá trị lưu trong cơ sở dữ liệu
+func nullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(*t), Valid: true}
+}
+
+// parseNullTime đọc thời điểm có thể rỗng, giữ múi giờ Việt Nam như các trường đã phân tích
+func parseNullTime(s sql.NullString) *time.Time {
+	t := parseTime(s)
+	if t.IsZero() {
+		return nil
+	}
+	t = t.In(models.VietnamLocation)
+	return &t
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultCategories là các danh mục mặc định cần thu thập, theo thứ tự trong menu "Quan hệ cổ đông"
+var DefaultCategories = []string{
+	"bao-cao-thuong-nien",
+	"bao-cao-tai-chinh",
+	"dieu-le-cong-ty",
+	"quy-che-quan-tri-cong-ty",
+	"cong-bao-thong-tin",
+	"ban-cao-bach",
+}
+
+// CategoryFolderMapping ánh xạ từ danh mục URL sang tên thư mục tiếng Việt mô tả.
+// Danh mục phát hiện tự động được thêm qua RegisterCategory
+var CategoryFolderMapping = map[string]string{
+	"bao-cao-thuong-nien":      "Báo cáo thường niên",
+	"bao-cao-tai-chinh":        "Báo cáo tài chính",
+	"dieu-le-cong-ty":          "Điều lệ công ty",
+	"quy-che-quan-tri-cong-ty": "Quy chế quản trị công ty",
+	"cong-bao-thong-tin":       "Công bố thông tin",
+	"ban-cao-bach":             "Bản cáo bạch",
+}
+
+// categoryMu bảo vệ CategoryFolderMapping khi đăng ký danh mục mới
+var categoryMu sync.RWMutex
+
+// RegisterCategory đăng ký tên hiển thị (cũng là tên thư mục) cho danh mục mới phát hiện.
+// Danh mục đã có ánh xạ giữ nguyên tên cũ để đường dẫn tệp không thay đổi. Trả về true nếu đã thêm
+func RegisterCategory(category, name string) bool {
+	name = strings.TrimSpace(strings.NewReplacer("/", "-", "\\", "-").Replace(name))
+	if category == "" || name == "" {
+		return false
+	}
+
+	categoryMu.Lock()
+	defer categoryMu.Unlock()
+
+	if _, ok := CategoryFolderMapping[category]; ok {
+		return false
+	}
+	CategoryFolderMapping[category] = name
+	return true
+}
+
+// CategoryFolder trả về tên thư mục của danh mục, dùng chính key nếu chưa có ánh xạ
+func CategoryFolder(category string) string {
+	categoryMu.RLock()
+	defer categoryMu.RUnlock()
+
+	if folder, ok := CategoryFolderMapping[category]; ok {
+		return folder
+	}
+	return category
+}
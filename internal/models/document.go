@@ -1,13 +1,16 @@
 package models
 
 import (
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Document đại diện cho một tài liệu từ trang web Netco
 type Document struct {
-	Name        string `json:"name"`
+	ID          string `json:"id"`   // khóa định danh ổn định, xem Key()
+	Name        string `json:"name"` // tên đầy đủ, ưu tiên thuộc tính title của liên kết
 	Size        string `json:"size"`
 	Downloads   string `json:"downloads"`
 	Modified    string `json:"modified"`
@@ -15,6 +18,39 @@ type Document struct {
 	DownloadURL string `json:"download_url"`
 	Category    string `json:"category"`
 	FilePath    string `json:"file_path"` // đường dẫn cục bộ sau khi tải về
+
+	CategoryName string `json:"category_name,omitempty"` // tên hiển thị tiếng Việt của danh mục
+
+	// Thông tin gốc của liên kết trên trang danh sách
+	DisplayName      string `json:"display_name,omitempty"`       // chữ hiển thị của liên kết, có thể bị cắt ngắn
+	OriginalFileName string `json:"original_file_name,omitempty"` // tên tệp đầy đủ trong thuộc tính title
+	FileType         string `json:"file_type,omitempty"`          // loại tệp khai báo qua biểu tượng, ví dụ "pdf"
+
+	// Thông tin tệp đã tải về
+	StoredFileName string `json:"stored_file_name,omitempty"` // tên tệp được chọn khi lưu (phần cuối của FilePath)
+	ServerFileName string `json:"server_file_name,omitempty"` // tên gốc trong Content-Disposition của máy chủ
+	MIMEType       string `json:"mime_type,omitempty"`        // kiểu MIME xác định từ nội dung tệp
+	SHA256         string `json:"sha256,omitempty"`           // mã băm SHA-256 (hex) của tệp đã lưu
+	FileSize       int64  `json:"file_size,omitempty"`        // kích thước thực của tệp đã lưu theo byte
+	SizeMismatch   bool   `json:"size_mismatch,omitempty"`    // FileSize không khớp kích thước trong danh sách
+	Version        int    `json:"version,omitempty"`          // số phiên bản hiện tại, tăng khi tệp được tải lên lại
+	ETag           string `json:"etag,omitempty"`             // header ETag khi tải tệp, dùng để phát hiện nội dung đổi
+	LastModified   string `json:"last_modified,omitempty"`    // header Last-Modified khi tải tệp
+
+	// Tham số của liên kết SharedFiles/Download.aspx
+	Site     string `json:"site,omitempty"`
+	PageID   int    `json:"page_id,omitempty"`
+	ModuleID int    `json:"module_id,omitempty"`
+	FileID   int    `json:"file_id,omitempty"`
+
+	// Các trường đã phân tích từ chuỗi gốc Size, Downloads, Modified
+	SizeBytes     int64      `json:"size_bytes"`            // kích thước theo byte (Size tính bằng KB)
+	DownloadCount int        `json:"download_count"`        // số lượt tải
+	ModifiedAt    *time.Time `json:"modified_at,omitempty"` // thời điểm sửa đổi (ISO-8601, giờ Việt Nam)
+	ParseErrors   []string   `json:"parse_errors,omitempty"`
+
+	DownloadError  string `json:"download_error,omitempty"`  // lỗi cuối cùng nếu tải xuống thất bại sau khi thử lại
+	QuarantinePath string `json:"quarantine_path,omitempty"` // nơi cách ly trang lỗi máy chủ trả về thay cho tệp
 }
 
 // CategoryFromURL trả về tên danh mục từ đường dẫn URL
@@ -31,28 +67,15 @@ func CategoryFromURL(url string) string {
 	return filepath.Base(url)
 }
 
-// CategoryFolderMapping ánh xạ từ danh mục URL sang tên thư mục tiếng Việt mô tả
-var CategoryFolderMapping = map[string]string{
-	"bao-cao-thuong-nien":      "Báo cáo thường niên",
-	"bao-cao-tai-chinh":        "Báo cáo tài chính",
-	"dieu-le-cong-ty":          "Điều lệ công ty",
-	"quy-che-quan-tri-cong-ty": "Quy chế quản trị công ty",
-	"cong-bao-thong-tin":       "Công bố thông tin",
-	"ban-cao-bach":             "Bản cáo bạch",
-}
-
-// FileName trả về tên tệp duy nhất dựa trên tên tài liệu
-func (d *Document) FileName() string {
-	// Loại bỏ các ký tự không hợp lệ cho tên tệp
-	name := strings.ReplaceAll(d.Name, "/", "_")
-	name = strings.ReplaceAll(name, "\\", "_")
-	name = strings.ReplaceAll(name, ":", "_")
-	name = strings.ReplaceAll(name, "*", "_")
-	name = strings.ReplaceAll(name, "?", "_")
-	name = strings.ReplaceAll(name, "\"", "_")
-	name = strings.ReplaceAll(name, "<", "_")
-	name = strings.ReplaceAll(name, ">", "_")
-	name = strings.ReplaceAll(name, "|", "_")
-
-	return name
+// FileTypeFromIcon trả về loại tệp từ đường dẫn biểu tượng, ví dụ "/Data/SiteImages/Icons/pdf.png" -> "pdf".
+// Trả về chuỗi rỗng nếu không phải biểu tượng loại tệp
+func FileTypeFromIcon(src string) string {
+	if i := strings.IndexAny(src, "?#"); i >= 0 {
+		src = src[:i]
+	}
+	dir, file := path.Split(src)
+	if !strings.EqualFold(path.Base(strings.TrimSuffix(dir, "/")), "Icons") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSuffix(file, path.Ext(file)))
 }
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/netco-crawler/internal/utils"
+)
+
+// FileName trả về tên tệp an toàn trên mọi hệ thống tệp dựa trên tên tài liệu (xem utils.SanitizeFileName).
+// Nếu tên chưa có phần mở rộng của loại tệp khai báo (FileType) thì phần mở rộng đó được thêm vào.
+// Hai tài liệu khác nhau có thể cùng tên, xem UniqueFileName và ResolveFilePaths
+func (d *Document) FileName() string {
+	return d.fileName("")
+}
+
+// UniqueFileName trả về FileName kèm hậu tố phân biệt trước phần mở rộng: fileid của liên kết
+// tải xuống, hoặc mã băm ngắn của URL nếu liên kết không có fileid
+func (d *Document) UniqueFileName() string {
+	if d.FileID > 0 {
+		return d.fileName(fmt.Sprintf("-%d", d.FileID))
+	}
+
+	sum := sha1.Sum([]byte(d.DownloadURL + "\x00" + d.Name))
+	return d.fileName("-" + hex.EncodeToString(sum[:4]))
+}
+
+// fileName làm sạch tên tài liệu, thêm phần mở rộng theo FileType và chèn suffix
+func (d *Document) fileName(suffix string) string {
+	name := utils.SanitizeFileName(d.Name)
+	if d.FileType != "" && !strings.EqualFold(path.Ext(name), "."+d.FileType) {
+		name += "." + strings.ToLower(d.FileType)
+	}
+
+	return utils.TruncateFileName(name, suffix)
+}
+
+// ResolveFilePaths đặt FilePath cho các tài liệu của cùng một danh mục. Tài liệu khác nhau
+// có tên tệp trùng nhau (không phân biệt hoa thường, như trên Windows và macOS) đều dùng
+// UniqueFileName để không ghi đè hoặc bỏ qua lẫn nhau khi tải xuống.
+// Tài liệu đã tải (có StoredFileName) giữ nguyên FilePath của tệp đã lưu
+func ResolveFilePaths(docs []Document) {
+	owners := make(map[string]map[string]bool)
+	names := make([]string, len(docs))
+
+	for i := range docs {
+		if docs[i].StoredFileName != "" && docs[i].FilePath != "" {
+			names[i] = strings.ToLower(docs[i].FilePath)
+		} else {
+			names[i] = strings.ToLower(filepath.Join(CategoryFolder(docs[i].Category), docs[i].FileName()))
+		}
+		if owners[names[i]] == nil {
+			owners[names[i]] = make(map[string]bool)
+		}
+		owners[names[i]][docs[i].Key()] = true
+	}
+
+	for i := range docs {
+		if docs[i].StoredFileName != "" && docs[i].FilePath != "" {
+			continue
+		}
+		name := docs[i].FileName()
+		if len(owners[names[i]]) > 1 {
+			name = docs[i].UniqueFileName()
+		}
+		docs[i].FilePath = filepath.Join(CategoryFolder(docs[i].Category), name)
+	}
+}
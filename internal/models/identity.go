@@ -0,0 +1,74 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ParseDownloadURL trích xuất site và các tham số pageid, mid, fileid từ liên kết
+// SharedFiles/Download.aspx. ok = false nếu liên kết không đúng định dạng
+func ParseDownloadURL(rawURL string) (site string, pageID, moduleID, fileID int, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || !strings.EqualFold(pathBase(u.Path), "Download.aspx") {
+		return "", 0, 0, 0, false
+	}
+
+	query := u.Query()
+	fileID, err = strconv.Atoi(query.Get("fileid"))
+	if err != nil || fileID <= 0 {
+		return "", 0, 0, 0, false
+	}
+
+	// pageid và mid chỉ mang tính tham khảo, thiếu cũng không làm liên kết không hợp lệ
+	pageID, _ = strconv.Atoi(query.Get("pageid"))
+	moduleID, _ = strconv.Atoi(query.Get("mid"))
+
+	return SiteFromHost(u.Host), pageID, moduleID, fileID, true
+}
+
+// SiteFromHost chuẩn hóa host thành định danh site (chữ thường, bỏ tiền tố "www.")
+func SiteFromHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// pathBase trả về phần cuối của đường dẫn URL
+func pathBase(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// ParseIdentity điền các trường định danh (Site, PageID, ModuleID, FileID, ID) từ DownloadURL.
+// Gọi lại an toàn cho dữ liệu cũ chưa có các trường này
+func (d *Document) ParseIdentity() {
+	if site, pageID, moduleID, fileID, ok := ParseDownloadURL(d.DownloadURL); ok {
+		d.Site = site
+		d.PageID = pageID
+		d.ModuleID = moduleID
+		d.FileID = fileID
+	}
+	d.ID = d.Key()
+}
+
+// Key trả về khóa định danh ổn định của tài liệu: "site:fileid" nếu liên kết hợp lệ, ngược lại là
+// "doc-" kèm mã băm của site, danh mục, tên và URL tải xuống. Khóa dự phòng không chứa "/" để dùng
+// được trong đường dẫn API (/api/documents/:id) và không phụ thuộc đường dẫn tệp vốn thay đổi khi tải
+func (d *Document) Key() string {
+	if d.FileID > 0 && d.Site != "" {
+		return fmt.Sprintf("%s:%d", d.Site, d.FileID)
+	}
+
+	site := d.Site
+	if site == "" {
+		if u, err := url.Parse(strings.TrimSpace(d.DownloadURL)); err == nil {
+			site = SiteFromHost(u.Host)
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{site, d.Category, d.Name, strings.TrimSpace(d.DownloadURL)}, "\x00")))
+	return "doc-" + hex.EncodeToString(sum[:12])
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	doc := Document{
+		Name:        "BCTC 2024.pdf",
+		DownloadURL: "https://www.netcovn.com.vn/SharedFiles/Download.aspx?pageid=40&mid=118&fileid=418",
+		Category:    "bao-cao-tai-chinh",
+	}
+	doc.ParseIdentity()
+	if got := doc.Key(); got != "netcovn.com.vn:418" {
+		t.Errorf("Key() = %q, want netcovn.com.vn:418", got)
+	}
+
+	invalid := Document{
+		Name:        "Nghị quyết.pdf",
+		DownloadURL: "https://netcovn.com.vn/Data/Sites/1/media/nghi-quyet.pdf",
+		Category:    "cong-bo-thong-tin",
+	}
+	invalid.ParseIdentity()
+	key := invalid.Key()
+	if !strings.HasPrefix(key, "doc-") || strings.ContainsAny(key, "/?#% ") {
+		t.Errorf("fallback Key() = %q, want a path-safe doc- token", key)
+	}
+
+	// Đường dẫn tệp thay đổi sau khi tải không làm đổi khóa
+	invalid.FilePath = "Công bố thông tin/Nghị quyết.pdf"
+	if got := invalid.Key(); got != key {
+		t.Errorf("Key() changed with FilePath: %q, want %q", got, key)
+	}
+
+	other := invalid
+	other.Category = "bao-cao-tai-chinh"
+	if other.Key() == key {
+		t.Error("documents in different categories share a fallback key")
+	}
+}
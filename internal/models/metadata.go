@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VietnamLocation là múi giờ Asia/Ho_Chi_Minh dùng để hiểu ngày sửa đổi trên trang Netco.
+// Nếu hệ thống không có dữ liệu múi giờ thì dùng UTC+7 cố định (Việt Nam không có giờ mùa hè)
+var VietnamLocation = loadVietnamLocation()
+
+func loadVietnamLocation() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Ho_Chi_Minh"); err == nil {
+		return loc
+	}
+	return time.FixedZone("ICT", 7*60*60)
+}
+
+// modifiedLayouts là các định dạng ngày của trang tiếng Việt (dd/MM/yyyy) và tiếng Anh (M/d/yyyy)
+var modifiedLayouts = []string{
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"2/1/2006 15:04:05",
+	"02/01/2006",
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 3:04 PM",
+}
+
+// ParseSizeKB chuyển kích thước dạng "14898" hoặc "14,898" (KB) thành số byte
+func ParseSizeKB(value string) (int64, error) {
+	cleaned := strings.NewReplacer(",", "", " ", "", "\u00a0", "").Replace(strings.TrimSpace(value))
+	if cleaned == "" {
+		return 0, fmt.Errorf("kích thước trống")
+	}
+
+	kb, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil || kb < 0 {
+		return 0, fmt.Errorf("kích thước không hợp lệ %q", value)
+	}
+
+	return int64(kb * 1024), nil
+}
+
+// ParseDownloadCount chuyển số lượt tải dạng "585" hoặc "1,234" thành số nguyên
+func ParseDownloadCount(value string) (int, error) {
+	cleaned := strings.NewReplacer(",", "", ".", "", " ", "", "\u00a0", "").Replace(strings.TrimSpace(value))
+	if cleaned == "" {
+		return 0, fmt.Errorf("số lượt tải trống")
+	}
+
+	count, err := strconv.Atoi(cleaned)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("số lượt tải không hợp lệ %q", value)
+	}
+
+	return count, nil
+}
+
+// ParseModified chuyển ngày sửa đổi dạng "17/01/2025 15:08:11" thành thời điểm theo giờ Việt Nam
+func ParseModified(value string) (time.Time, error) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return time.Time{}, fmt.Errorf("ngày sửa đổi trống")
+	}
+
+	for _, layout := range modifiedLayouts {
+		if t, err := time.ParseInLocation(layout, value, VietnamLocation); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("ngày sửa đổi không hợp lệ %q", value)
+}
+
+// SizeMatches cho biết kích thước thực (byte) có khớp cột "Kích thước (KB)" của danh sách không.
+// Danh sách làm tròn theo KB nên cho phép lệch dưới 1 KB; luôn khớp nếu danh sách không có kích thước
+func (d *Document) SizeMatches(size int64) bool {
+	if d.SizeBytes <= 0 {
+		return true
+	}
+	diff := size - d.SizeBytes
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff < 1024
+}
+
+// ParseMetadata điền các trường kiểu số và thời gian từ chuỗi gốc.
+// Lỗi phân tích được ghi vào ParseErrors thay vì làm hỏng cả tài liệu
+func (d *Document) ParseMetadata() {
+	d.ParseErrors = nil
+	d.SizeBytes = 0
+	d.DownloadCount = 0
+	d.ModifiedAt = nil
+
+	if size, err := ParseSizeKB(d.Size); err != nil {
+		d.ParseErrors = append(d.ParseErrors, err.Error())
+	} else {
+		d.SizeBytes = size
+	}
+
+	if count, err := ParseDownloadCount(d.Downloads); err != nil {
+		d.ParseErrors = append(d.ParseErrors, err.Error())
+	} else {
+		d.DownloadCount = count
+	}
+
+	if modified, err := ParseModified(d.Modified); err != nil {
+		d.ParseErrors = append(d.ParseErrors, err.Error())
+	} else {
+		d.ModifiedAt = &modified
+	}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSizeKB(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"14898", 14898 * 1024, false},
+		{"14,898", 14898 * 1024, false},
+		{" 713 ", 713 * 1024, false},
+		{"1 024", 1024 * 1024, false},
+		{"0.5", 512, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"   ", 0, true},
+		{"-1", 0, true},
+		{"14 KB", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSizeKB(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSizeKB(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSizeKB(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseModified(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, VietnamLocation)
+	}
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"17/01/2025 15:08:11", date(2025, time.January, 17, 15, 8, 11), false},
+		{"  17/01/2025   15:08:11 ", date(2025, time.January, 17, 15, 8, 11), false},
+		{"17/01/2025 15:08", date(2025, time.January, 17, 15, 8, 0), false},
+		{"5/3/2024 09:30:00", date(2024, time.March, 5, 9, 30, 0), false}, // dd/MM như trang tiếng Việt
+		{"17/01/2025", date(2025, time.January, 17, 0, 0, 0), false},
+		{"1/17/2025 3:08:11 PM", date(2025, time.January, 17, 15, 8, 11), false}, // trang tiếng Anh
+		{"1/17/2025 3:08 PM", date(2025, time.January, 17, 15, 8, 0), false},
+		{"", time.Time{}, true},
+		{"31/02/2025 10:00:00", time.Time{}, true},
+		{"2025-01-17T15:08:11Z", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseModified(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseModified(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseModified(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	// Giờ Việt Nam là UTC+7
+	got, _ := ParseModified("17/01/2025 07:00:00")
+	if want := time.Date(2025, time.January, 17, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ParseModified in UTC = %v, want %v", got.UTC(), want)
+	}
+}
+
+func TestParseMetadataRecordsErrors(t *testing.T) {
+	doc := Document{Size: "14,898", Downloads: "n/a", Modified: "17/01/2025 15:08:11"}
+	doc.ParseMetadata()
+
+	if doc.SizeBytes != 14898*1024 || doc.DownloadCount != 0 || doc.ModifiedAt == nil {
+		t.Errorf("unexpected fields: size=%d downloads=%d modified=%v", doc.SizeBytes, doc.DownloadCount, doc.ModifiedAt)
+	}
+	if len(doc.ParseErrors) != 1 {
+		t.Errorf("ParseErrors = %q, want one error for downloads", doc.ParseErrors)
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"path"
+	"path/filepath"
+
+	"github.com/netco-crawler/internal/utils"
+)
+
+// VersionsDir là thư mục (trong kho lưu trữ) chứa tệp của các phiên bản cũ
+const VersionsDir = ".versions"
+
+// ListingChanged cho biết ngày sửa đổi hoặc kích thước trong danh sách khác với prev của lần
+// thu thập trước, dấu hiệu tệp đã được tải lên lại. Trường chưa phân tích được thì không so sánh
+func (d *Document) ListingChanged(prev Document) bool {
+	if d.ModifiedAt != nil && prev.ModifiedAt != nil && !d.ModifiedAt.Equal(*prev.ModifiedAt) {
+		return true
+	}
+	return d.SizeBytes > 0 && prev.SizeBytes > 0 && d.SizeBytes != prev.SizeBytes
+}
+
+// CopyStoredFile chép thông tin tệp đã tải của prev (đường dẫn, tên tệp, kiểu MIME, mã băm, kích thước)
+// sang tài liệu, dùng khi tệp không được tải lại trong lần thu thập này
+func (d *Document) CopyStoredFile(prev Document) {
+	d.FilePath = prev.FilePath
+	d.StoredFileName = prev.StoredFileName
+	d.ServerFileName = prev.ServerFileName
+	d.MIMEType = prev.MIMEType
+	d.SHA256 = prev.SHA256
+	d.FileSize = prev.FileSize
+	d.SizeMismatch = prev.SizeMismatch
+	d.ETag = prev.ETag
+	d.LastModified = prev.LastModified
+}
+
+// VersionFilePath trả về đường dẫn lưu phiên bản cũ của tệp filePath có mã băm sum, ví dụ
+// ".versions/Báo cáo tài chính/BCTC 2024-3f2a9c1b.pdf"
+func VersionFilePath(filePath, sum string) string {
+	if len(sum) > 8 {
+		sum = sum[:8]
+	}
+	dir, name := path.Split(filepath.ToSlash(filePath))
+	return path.Join(VersionsDir, dir, utils.TruncateFileName(name, "-"+sum))
+}
@@ -0,0 +1,185 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules là tập luật robots.txt áp dụng cho một User-Agent cụ thể
+type Rules struct {
+	rules      []rule
+	CrawlDelay time.Duration // Khoảng cách tối thiểu giữa hai yêu cầu, 0 nếu không quy định
+}
+
+// rule là một dòng Allow hoặc Disallow
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// group là một nhóm luật dành cho một hoặc nhiều User-Agent
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+// AllowAll trả về tập luật cho phép mọi đường dẫn
+func AllowAll() *Rules {
+	return &Rules{}
+}
+
+// DisallowAll trả về tập luật chặn mọi đường dẫn
+func DisallowAll() *Rules {
+	return &Rules{rules: []rule{{allow: false, pattern: "/"}}}
+}
+
+// Parse phân tích nội dung robots.txt và chọn nhóm luật phù hợp nhất với userAgent.
+// Nhóm có tên agent dài nhất khớp với userAgent được ưu tiên, nếu không có sẽ dùng nhóm "*"
+func Parse(r io.Reader, userAgent string) (*Rules, error) {
+	var groups []*group
+	var current *group
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Các dòng User-agent liên tiếp thuộc cùng một nhóm
+			if current == nil || !lastWasAgent {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if current != nil && (value != "" || key == "allow") {
+				current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		lastWasAgent = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	selected := selectGroup(groups, userAgent)
+	if selected == nil {
+		return AllowAll(), nil
+	}
+
+	return &Rules{rules: selected.rules, CrawlDelay: selected.crawlDelay}, nil
+}
+
+// selectGroup chọn nhóm có tên agent dài nhất là chuỗi con của tên sản phẩm trong userAgent
+func selectGroup(groups []*group, userAgent string) *group {
+	product := strings.ToLower(userAgent)
+	if i := strings.IndexAny(product, "/ "); i >= 0 {
+		product = product[:i]
+	}
+
+	var best, wildcard *group
+	bestLen := 0
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				if wildcard == nil {
+					wildcard = g
+				}
+				continue
+			}
+			if agent != "" && strings.Contains(product, agent) && len(agent) > bestLen {
+				best = g
+				bestLen = len(agent)
+			}
+		}
+	}
+
+	if best != nil {
+		return best
+	}
+	return wildcard
+}
+
+// Allowed kiểm tra đường dẫn (gồm cả query string) có được phép truy cập không.
+// Luật khớp dài nhất thắng, Allow thắng khi độ dài bằng nhau
+func (r *Rules) Allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	allowed := true
+	matchLen := -1
+	for _, rl := range r.rules {
+		if rl.pattern == "" {
+			continue
+		}
+		if !match(rl.pattern, path) {
+			continue
+		}
+		if len(rl.pattern) > matchLen || (len(rl.pattern) == matchLen && rl.allow) {
+			allowed = rl.allow
+			matchLen = len(rl.pattern)
+		}
+	}
+
+	return allowed
+}
+
+// match so khớp path với mẫu robots.txt hỗ trợ ký tự đại diện "*" và neo cuối "$"
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	for _, part := range parts[1:] {
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+
+	if anchored {
+		last := parts[len(parts)-1]
+		if len(parts) == 1 {
+			return pos == len(path)
+		}
+		// Mẫu kết thúc bằng "*...$": phần cuối phải khớp đúng đuôi của path
+		return strings.HasSuffix(path, last)
+	}
+
+	return true
+}
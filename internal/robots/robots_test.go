@@ -0,0 +1,123 @@
+package robots
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	const netco = `# robots.txt
+User-agent: *
+Disallow: /SharedFiles/
+Allow: /SharedFiles/Download.aspx
+Crawl-delay: 2
+
+User-agent: BadBot
+User-agent: OtherBot
+Disallow: /
+
+User-agent: NetcoCrawler
+Disallow: /admin
+Disallow: /*.aspx$
+Allow: /SharedFiles/Download.aspx
+Crawl-delay: 0.5
+`
+
+	tests := []struct {
+		name      string
+		robots    string
+		userAgent string
+		delay     time.Duration
+		allowed   map[string]bool
+	}{
+		{
+			name:      "wildcard group",
+			robots:    netco,
+			userAgent: "Mozilla/5.0",
+			delay:     2 * time.Second,
+			allowed: map[string]bool{
+				"/":                               true,
+				"/bao-cao-tai-chinh?pagenumber=2": true,
+				"/SharedFiles/other.aspx":         false,
+				"/SharedFiles/Download.aspx?pageid=40&mid=118&fileid=418": true,
+			},
+		},
+		{
+			name:      "group with several agents",
+			robots:    netco,
+			userAgent: "OtherBot/2.0",
+			allowed: map[string]bool{
+				"/":                  false,
+				"/bao-cao-tai-chinh": false,
+			},
+		},
+		{
+			name:      "named group wins over wildcard",
+			robots:    netco,
+			userAgent: "NetcoCrawler/1.0 (+https://example.com)",
+			delay:     500 * time.Millisecond,
+			allowed: map[string]bool{
+				"/admin/users":                    false,
+				"/default.aspx":                   false,
+				"/default.aspx?x=1":               true,
+				"/SharedFiles/Download.aspx":      true,
+				"/bao-cao-tai-chinh?pagenumber=1": true,
+			},
+		},
+		{
+			name:      "empty disallow allows everything",
+			robots:    "User-agent: *\nDisallow:\n",
+			userAgent: "NetcoCrawler/1.0",
+			allowed:   map[string]bool{"/": true, "/SharedFiles/Download.aspx": true},
+		},
+		{
+			name:      "no matching group",
+			robots:    "User-agent: BadBot\nDisallow: /\n",
+			userAgent: "NetcoCrawler/1.0",
+			allowed:   map[string]bool{"/": true},
+		},
+		{
+			name:      "rules before any user-agent are ignored",
+			robots:    "Disallow: /\nCrawl-delay: 10\n",
+			userAgent: "NetcoCrawler/1.0",
+			allowed:   map[string]bool{"/": true},
+		},
+		{
+			name:      "invalid crawl-delay",
+			robots:    "User-agent: *\nCrawl-delay: soon\nDisallow: /private # comment\n",
+			userAgent: "NetcoCrawler/1.0",
+			allowed:   map[string]bool{"/private/a": false, "/public": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := Parse(strings.NewReader(tt.robots), tt.userAgent)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if rules.CrawlDelay != tt.delay {
+				t.Errorf("CrawlDelay = %v, want %v", rules.CrawlDelay, tt.delay)
+			}
+			for path, want := range tt.allowed {
+				if got := rules.Allowed(path); got != want {
+					t.Errorf("Allowed(%q) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowAllDisallowAll(t *testing.T) {
+	if !AllowAll().Allowed("/SharedFiles/Download.aspx") {
+		t.Error("AllowAll blocked a path")
+	}
+	if DisallowAll().Allowed("/") {
+		t.Error("DisallowAll allowed a path")
+	}
+	var nilRules *Rules
+	if !nilRules.Allowed("/") {
+		t.Error("nil rules blocked a path")
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local lưu đối tượng thành tệp trong một thư mục trên máy
+type Local struct {
+	dir string
+}
+
+// NewLocal tạo kho lưu trữ tại thư mục dir
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+// Dir trả về thư mục gốc của kho
+func (l *Local) Dir() string {
+	return l.dir
+}
+
+// LocalPath trả về đường dẫn tệp của key trên máy
+func (l *Local) LocalPath(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put ghi nội dung vào tệp tạm rồi đổi tên để người đọc không thấy tệp dở dang
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := l.LocalPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Get mở tệp của key. Body trả về là *os.File nên hỗ trợ io.Seeker
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	path, err := l.LocalPath(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, nil, fmt.Errorf("%s là thư mục: %w", key, ErrNotExist)
+	}
+
+	return file, l.objectInfo(key, info), nil
+}
+
+// Stat trả về thông tin tệp của key
+func (l *Local) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	path, err := l.LocalPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s là thư mục: %w", key, ErrNotExist)
+	}
+
+	return l.objectInfo(key, info), nil
+}
+
+// List duyệt thư mục và trả về các tệp có khóa bắt đầu bằng prefix, bỏ qua tệp tạm đang ghi
+func (l *Local) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := filepath.WalkDir(l.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || isTemporary(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		// Stat đi theo symlink của cây liên kết tới kho tệp theo nội dung
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, *l.objectInfo(key, info))
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+
+	return objects, err
+}
+
+// Delete xóa tệp của key
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.LocalPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// isTemporary cho biết tệp là tệp tạm của lần tải dở dang hoặc liên kết đang tạo
+func isTemporary(path string) bool {
+	return strings.HasSuffix(path, ".tmp") || strings.HasSuffix(path, ".tmp.meta") || strings.HasSuffix(path, ".link")
+}
+
+func (l *Local) objectInfo(key string, info fs.FileInfo) *ObjectInfo {
+	key, _ = CleanKey(key)
+	return &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 là bản giả tối thiểu của dịch vụ S3 kiểu path-style: PUT, GET, HEAD, DELETE và
+// ListObjectsV2 (mỗi trang tối đa pageSize đối tượng để kiểm tra việc đọc tiếp)
+type fakeS3 struct {
+	*httptest.Server
+
+	bucket   string
+	pageSize int
+
+	mu       sync.Mutex
+	objects  map[string]fakeObject
+	unsigned []string // các yêu cầu thiếu chữ ký SigV4
+}
+
+type fakeObject struct {
+	body        []byte
+	contentType string
+	modified    time.Time
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+
+	s := &fakeS3{bucket: "netco", pageSize: 2, objects: make(map[string]fakeObject)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test-access/") || r.Header.Get("X-Amz-Date") == "" {
+		s.unsigned = append(s.unsigned, r.Method+" "+r.URL.Path)
+	}
+
+	bucketPath := "/" + s.bucket
+	if r.URL.Path == bucketPath && r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+		s.list(w, r)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, bucketPath+"/")
+	if !ok {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[key] = fakeObject{body: body, contentType: r.Header.Get("Content-Type"), modified: time.Now()}
+	case http.MethodGet, http.MethodHead:
+		object, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.body)))
+		w.Header().Set("Last-Modified", object.modified.UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(object.body)
+		}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	end := min(start+s.pageSize, len(keys))
+
+	type content struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}{IsTruncated: end < len(keys)}
+	for _, key := range keys[start:end] {
+		object := s.objects[key]
+		result.Contents = append(result.Contents, content{key, int64(len(object.body)), object.modified})
+	}
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func TestS3(t *testing.T) {
+	server := newFakeS3(t)
+	store, err := NewS3(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    server.bucket,
+		Prefix:    "netco/",
+		AccessKey: "test-access",
+		SecretKey: "test-secret",
+	}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	files := map[string]string{
+		"Báo cáo tài chính/BCTC 2024.pdf":     "%PDF-1.4 bctc",
+		"Báo cáo tài chính/BCTC 2023.pdf":     "%PDF-1.4 cu",
+		"Công bố thông tin/Nghị quyết #1.pdf": "%PDF-1.4 nq",
+	}
+	for key, body := range files {
+		if err := store.Put(ctx, key, strings.NewReader(body), int64(len(body)), "application/pdf"); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+	if _, ok := server.objects["netco/Báo cáo tài chính/BCTC 2024.pdf"]; !ok {
+		t.Errorf("object not stored under the prefix: %v", server.objects)
+	}
+
+	body, info, err := store.Get(ctx, "/Báo cáo tài chính/BCTC 2024.pdf")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "%PDF-1.4 bctc" || info.Size != int64(len(data)) || info.ContentType != "application/pdf" {
+		t.Errorf("Get = %q, %+v", data, info)
+	}
+
+	info, err = store.Stat(ctx, "Công bố thông tin/Nghị quyết #1.pdf")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Key != "Công bố thông tin/Nghị quyết #1.pdf" || info.Size != 11 || info.ModTime.IsZero() {
+		t.Errorf("Stat = %+v", info)
+	}
+
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != len(files) {
+		t.Fatalf("List returned %d objects, want %d: %v", len(objects), len(files), objects)
+	}
+	for _, object := range objects {
+		if body, ok := files[object.Key]; !ok || object.Size != int64(len(body)) {
+			t.Errorf("List returned unexpected object %+v", object)
+		}
+	}
+	objects, err = store.List(ctx, "Báo cáo tài chính/")
+	if err != nil || len(objects) != 2 {
+		t.Errorf("List(prefix) = %v, %v, want 2 objects", objects, err)
+	}
+
+	if err := store.Delete(ctx, "Báo cáo tài chính/BCTC 2023.pdf"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Stat(ctx, "Báo cáo tài chính/BCTC 2023.pdf"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat after Delete: %v, want ErrNotExist", err)
+	}
+	if _, _, err := store.Get(ctx, "Báo cáo tài chính/BCTC 2023.pdf"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Get after Delete: %v, want ErrNotExist", err)
+	}
+	if err := store.Delete(ctx, "Báo cáo tài chính/BCTC 2023.pdf"); err != nil {
+		t.Errorf("Delete of missing object: %v", err)
+	}
+
+	if _, err := store.Stat(ctx, "../ngoài kho.pdf"); err == nil {
+		t.Error("Stat accepted a key outside the store")
+	}
+	if len(server.unsigned) > 0 {
+		t.Errorf("unsigned requests: %v", server.unsigned)
+	}
+}
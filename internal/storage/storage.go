@@ -0,0 +1,62 @@
+// Package storage trừu tượng hóa nơi lưu tài liệu đã tải: thư mục trên máy (Local) hoặc
+// dịch vụ tương thích S3 (S3, ví dụ AWS S3 hoặc MinIO), để crawler và web server có thể
+// chạy trên các máy khác nhau.
+//
+// Khóa của đối tượng là đường dẫn tương đối phân tách bằng "/", ví dụ
+// "Báo cáo tài chính/BCTC 2024.pdf", giống FilePath của tài liệu.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotExist là lỗi khi đối tượng không tồn tại, tương thích errors.Is(err, fs.ErrNotExist)
+var ErrNotExist = fs.ErrNotExist
+
+// ObjectInfo mô tả một đối tượng trong kho lưu trữ
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string // có thể rỗng nếu kho không lưu kiểu nội dung
+}
+
+// Storage là kho lưu tài liệu
+type Storage interface {
+	// Put ghi nội dung r (size byte) vào key, thay thế đối tượng cũ nếu có
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get mở đối tượng để đọc, người gọi phải đóng body trả về
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+
+	// Stat trả về thông tin đối tượng, lỗi ErrNotExist nếu không có
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	// List liệt kê các đối tượng có khóa bắt đầu bằng prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+
+	// Delete xóa đối tượng, không lỗi nếu đối tượng không tồn tại
+	Delete(ctx context.Context, key string) error
+}
+
+// CleanKey chuẩn hóa khóa (dùng "/", bỏ "/" ở đầu) và từ chối khóa thoát ra ngoài kho
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("khóa không hợp lệ: %q", key)
+		}
+	}
+
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" {
+		return "", fmt.Errorf("khóa không hợp lệ: %q", key)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// HashFile trả về mã băm SHA-256 (hex) và số byte của tệp
+func HashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	return HashReader(file)
+}
+
+// HashReader trả về mã băm SHA-256 (hex) và số byte đọc được từ r, dùng cho tệp trong kho lưu trữ
+func HashReader(r io.Reader) (string, int64, error) {
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// hashPrefix đưa n byte đầu của tệp vào hasher, dùng khi tiếp tục một lần tải dở dang
+func hashPrefix(hasher io.Writer, path string, n int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.CopyN(hasher, file, n)
+	return err
+}
@@ -1,53 +1,341 @@
 package utils
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+// DefaultUserAgent là định danh mặc định kèm thông tin liên hệ của crawler
+const DefaultUserAgent = "NetcoCrawler/1.0 (+https://github.com/huychiendev/netco-crawler)"
+
+// DefaultHTTPClient tạo http.Client với các timeout hợp lý cho việc thu thập
+func DefaultHTTPClient() *http.Client {
+	return NewHTTPClient(30*time.Second, 60*time.Second)
+}
+
+// NewHTTPClient tạo http.Client với timeout kết nối và timeout chờ header phản hồi.
+// Không đặt Client.Timeout để các tệp lớn không bị cắt ngang khi đang tải
+func NewHTTPClient(connectTimeout, responseTimeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   connectTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   connectTimeout,
+			ResponseHeaderTimeout: responseTimeout,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          100,
+		},
+	}
+}
+
+// Downloader tải tệp bằng http.Client và chính sách thử lại đã cấu hình
+type Downloader struct {
+	Client    *http.Client
+	Retry     RetryPolicy
+	UserAgent string // Gửi trong header User-Agent nếu khác rỗng
+
+	// QuarantineDir là nơi lưu phản hồi HTML (trang lỗi, trang đăng nhập) trả về thay cho tệp,
+	// rỗng thì các phản hồi này bị xóa
+	QuarantineDir string
+}
+
+// NewDownloader tạo Downloader, client nil sẽ dùng http.DefaultClient
+func NewDownloader(client *http.Client, policy RetryPolicy) *Downloader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Downloader{Client: client, Retry: policy}
+}
+
 // DownloadFile tải xuống tệp từ URL và lưu vào đường dẫn đã chỉ định
 func DownloadFile(url, destPath string) error {
+	return DownloadFileWithClient(http.DefaultClient, url, destPath)
+}
+
+// DownloadFileWithClient tải xuống tệp bằng client đã cho và lưu vào đường dẫn đã chỉ định
+func DownloadFileWithClient(client *http.Client, url, destPath string) error {
+	return DownloadFileContext(context.Background(), client, url, destPath)
+}
+
+// DownloadFileContext tải xuống tệp với chính sách thử lại mặc định và có thể bị hủy qua ctx
+func DownloadFileContext(ctx context.Context, client *http.Client, url, destPath string) error {
+	return NewDownloader(client, DefaultRetryPolicy()).Download(ctx, url, destPath)
+}
+
+// Download tải tệp, thử lại khi gặp lỗi tạm thời theo chính sách của Downloader.
+// Tên tệp có thể khác destPath, xem Fetch
+func (d *Downloader) Download(ctx context.Context, url, destPath string) error {
+	_, err := d.Fetch(ctx, url, destPath)
+	return err
+}
+
+// Fetch tải tệp vào thư mục của destPath và trả về thông tin tệp đã lưu. Tên tệp lấy từ
+// Content-Disposition nếu máy chủ gửi, ngược lại là tên của destPath; phần mở rộng được
+// điều chỉnh theo kiểu MIME xác định từ nội dung
+func (d *Downloader) Fetch(ctx context.Context, url, destPath string) (*DownloadResult, error) {
+	var result *DownloadResult
+	err := Retry(ctx, d.Retry, func(attempt int) error {
+		if attempt > 1 {
+			log.Printf("Thử tải lại lần %d: %s", attempt, url)
+		}
+		var err error
+		result, err = d.downloadOnce(ctx, url, destPath)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// downloadOnce thực hiện một lần tải tệp. Nếu còn tệp tạm của lần trước kèm thông tin xác thực,
+// chỉ phần còn thiếu được yêu cầu bằng Range/If-Range; khi không thể tiếp tục sẽ tải lại từ đầu.
+// Tệp tạm chỉ được giữ lại khi lỗi nếu có thể tiếp tục, ngược lại sẽ bị xóa
+func (d *Downloader) downloadOnce(ctx context.Context, url, destPath string) (result *DownloadResult, err error) {
 	// Tạo thư mục đích nếu chưa tồn tại
 	dir := filepath.Dir(destPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("không thể tạo thư mục đích: %w", err)
+		return nil, fmt.Errorf("không thể tạo thư mục đích: %w", err)
 	}
 
-	// Tạo tệp tạm
 	tmpPath := destPath + ".tmp"
-	out, err := os.Create(tmpPath)
-	if err != nil {
-		return fmt.Errorf("không thể tạo tệp tạm: %w", err)
+	partial, offset := loadPartialDownload(tmpPath, url)
+
+	var resp *http.Response
+	if partial != nil {
+		log.Printf("Tiếp tục tải %s từ byte %d", url, offset)
+		resp, err = d.get(ctx, url, func(req *http.Request) { partial.setRangeHeaders(req, offset) })
+		if err != nil {
+			return nil, fmt.Errorf("không thể tải tệp: %w", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			if verr := partial.validate(resp, offset); verr != nil {
+				log.Printf("Không thể tiếp tục tải %s: %v, tải lại từ đầu", url, verr)
+				resp.Body.Close()
+				resp = nil
+			}
+		case http.StatusRequestedRangeNotSatisfiable:
+			resp.Body.Close()
+			if partial.complete(resp, offset) {
+				// Tệp tạm đã đủ, chỉ cần đổi tên
+				return d.finishDownload(url, tmpPath, destPath, resp.Header, "")
+			}
+			resp = nil
+		case http.StatusOK:
+			// Máy chủ bỏ qua Range hoặc tệp đã thay đổi (If-Range không khớp): nhận toàn bộ tệp
+			offset = 0
+		default:
+			resp.Body.Close()
+			return nil, NewStatusError(resp)
+		}
+
+		if resp == nil {
+			removePartialDownload(tmpPath)
+			offset = 0
+		}
 	}
-	defer out.Close()
 
-	// Lấy nội dung từ URL
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("không thể tải tệp: %w", err)
+	if resp == nil {
+		if resp, err = d.get(ctx, url, nil); err != nil {
+			return nil, fmt.Errorf("không thể tải tệp: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, NewStatusError(resp)
+		}
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("phản hồi lỗi: %s", resp.Status)
+	// Mở tệp tạm: nối tiếp khi máy chủ trả về phần còn lại, ngược lại ghi mới
+	var out *os.File
+	if offset > 0 {
+		out, err = os.OpenFile(tmpPath, os.O_WRONLY|os.O_APPEND, 0644)
+	} else {
+		partial = newPartialDownload(url, resp)
+		out, err = os.Create(tmpPath)
+		if err == nil && partial != nil {
+			if serr := partial.save(tmpPath); serr != nil {
+				partial = nil
+			}
+		}
+		if partial == nil {
+			os.Remove(tmpPath + ".meta")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo tệp tạm: %w", err)
+	}
+	defer out.Close()
+
+	// Khi có lỗi: giữ phần đã tải nếu lần sau có thể tiếp tục, ngược lại dọn dẹp tệp dở dang
+	defer func() {
+		if err != nil {
+			out.Close()
+			if partial == nil {
+				removePartialDownload(tmpPath)
+			}
+		}
+	}()
+
+	// Tính SHA-256 ngay khi ghi; khi tải tiếp, phần đã có trong tệp tạm được băm trước
+	hasher := sha256.New()
+	if offset > 0 {
+		if err = hashPrefix(hasher, tmpPath, offset); err != nil {
+			return nil, fmt.Errorf("không thể đọc tệp tạm: %w", err)
+		}
 	}
 
 	// Sao chép nội dung vào tệp
-	_, err = io.Copy(out, resp.Body)
+	if _, err = io.Copy(io.MultiWriter(out, hasher), resp.Body); err != nil {
+		return nil, fmt.Errorf("lỗi khi sao chép nội dung: %w", err)
+	}
+	if err = out.Close(); err != nil {
+		return nil, fmt.Errorf("không thể ghi tệp tạm: %w", err)
+	}
+
+	return d.finishDownload(url, tmpPath, destPath, resp.Header, hex.EncodeToString(hasher.Sum(nil)))
+}
+
+// finishDownload chọn tên tệp cuối cùng (Content-Disposition, kiểu MIME của nội dung)
+// và đổi tên tệp tạm thành tệp đích trong thư mục của destPath. sum là SHA-256 đã tính
+// khi tải, rỗng thì băm lại tệp tạm. Trang HTML trả về thay cho tệp được chuyển vào
+// thư mục cách ly và trả về InvalidContentError
+func (d *Downloader) finishDownload(url, tmpPath, destPath string, header http.Header, sum string) (*DownloadResult, error) {
+	mimeType, err := SniffMIME(tmpPath, header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc tệp tạm: %w", err)
+	}
+
+	reason, err := DetectErrorPage(tmpPath, header.Get("Content-Type"), filepath.Base(destPath))
 	if err != nil {
-		return fmt.Errorf("lỗi khi sao chép nội dung: %w", err)
+		return nil, fmt.Errorf("không thể đọc tệp tạm: %w", err)
+	}
+	if reason != "" {
+		os.Remove(tmpPath + ".meta")
+		path, qerr := d.quarantine(tmpPath, destPath, mimeType)
+		if qerr != nil {
+			log.Printf("Không thể cách ly phản hồi của %s: %v", url, qerr)
+		} else if path != "" {
+			log.Printf("Đã cách ly phản hồi của %s vào %s", url, path)
+		}
+		return nil, &InvalidContentError{URL: url, Reason: reason, Path: path}
+	}
+	if sum == "" {
+		if sum, _, err = HashFile(tmpPath); err != nil {
+			return nil, fmt.Errorf("không thể đọc tệp tạm: %w", err)
+		}
+	}
+
+	serverName := ContentDispositionFilename(header.Get("Content-Disposition"))
+	name := serverName
+	if name == "" {
+		name = filepath.Base(destPath)
+	}
+	finalPath := filepath.Join(filepath.Dir(destPath), FixExtension(name, mimeType))
+
+	// Tên của máy chủ có thể trùng tệp của tài liệu khác trong cùng thư mục: chỉ ghi đè khi
+	// nội dung giống hệt, ngược lại dùng tên riêng của tài liệu (destPath)
+	if ownPath := filepath.Join(filepath.Dir(destPath), FixExtension(filepath.Base(destPath), mimeType)); finalPath != ownPath {
+		if existing, _, err := HashFile(finalPath); err == nil && existing != sum {
+			log.Printf("Tên %s của máy chủ đã được tệp khác sử dụng, lưu thành %s", filepath.Base(finalPath), filepath.Base(ownPath))
+			finalPath = ownPath
+		}
 	}
-	out.Close()
 
 	// Đổi tên tệp tạm thành tệp đích
-	if err := os.Rename(tmpPath, destPath); err != nil {
-		return fmt.Errorf("không thể đổi tên tệp tạm: %w", err)
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return nil, fmt.Errorf("không thể đổi tên tệp tạm: %w", err)
+	}
+	os.Remove(tmpPath + ".meta")
+
+	info, err := os.Stat(finalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DownloadResult{
+		Path:           finalPath,
+		FileName:       filepath.Base(finalPath),
+		ServerFileName: serverName,
+		MIMEType:       mimeType,
+		Size:           info.Size(),
+		SHA256:         sum,
+		ETag:           header.Get("ETag"),
+		LastModified:   header.Get("Last-Modified"),
+	}, nil
+}
+
+// Changed hỏi máy chủ bằng yêu cầu HEAD có điều kiện (If-None-Match, If-Modified-Since) xem tệp tại url
+// có còn là bản có ETag etag và Last-Modified lastModified đã lưu hay không. Máy chủ không hỗ trợ yêu cầu
+// có điều kiện vẫn được nhận ra qua header của phản hồi; phản hồi không có cả hai header được coi là chưa đổi
+func (d *Downloader) Changed(ctx context.Context, url, etag, lastModified string) (bool, error) {
+	var changed bool
+	err := Retry(ctx, d.Retry, func(attempt int) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return fmt.Errorf("không thể tạo yêu cầu: %w", err)
+		}
+		if d.UserAgent != "" {
+			req.Header.Set("User-Agent", d.UserAgent)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+
+		resp, err := d.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified:
+			changed = false
+		case resp.StatusCode != http.StatusOK:
+			return NewStatusError(resp)
+		case etag != "" && resp.Header.Get("ETag") != "":
+			changed = resp.Header.Get("ETag") != etag
+		case lastModified != "" && resp.Header.Get("Last-Modified") != "":
+			changed = resp.Header.Get("Last-Modified") != lastModified
+		default:
+			changed = false
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// get gửi yêu cầu GET kèm User-Agent, prepare (nếu có) bổ sung header cho yêu cầu
+func (d *Downloader) get(ctx context.Context, url string, prepare func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo yêu cầu: %w", err)
+	}
+	if d.UserAgent != "" {
+		req.Header.Set("User-Agent", d.UserAgent)
+	}
+	if prepare != nil {
+		prepare(req)
 	}
 
-	return nil
+	return d.Client.Do(req)
 }
 
 // EnsureDirectoryExists đảm bảo thư mục đã cho tồn tại
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errorPageSniffLen là số byte đầu của tệp được đọc để nhận diện trang lỗi
+const errorPageSniffLen = 8 * 1024
+
+// errorPageMarkers là các dấu hiệu của trang lỗi ASP.NET, trang đăng nhập hoặc trang báo lỗi
+// của mojoPortal mà Download.aspx có thể trả về với mã 200 thay cho tệp tài liệu
+var errorPageMarkers = []struct {
+	marker string
+	reason string
+}{
+	{"server error in '/' application", "lỗi ASP.NET"},
+	{"runtime error", "lỗi ASP.NET"},
+	{"login.aspx", "trang đăng nhập"},
+	{"accessdenied.aspx", "từ chối truy cập"},
+	{"access denied", "từ chối truy cập"},
+	{"pagenotfound", "không tìm thấy tệp"},
+	{"mojoportal", "trang của mojoPortal"},
+}
+
+// textExtensions là các phần mở rộng mà nội dung HTML hoặc văn bản là hợp lệ
+var textExtensions = map[string]bool{".htm": true, ".html": true, ".txt": true}
+
+// InvalidContentError là lỗi khi máy chủ trả về trang HTML (trang lỗi, trang đăng nhập)
+// thay cho tệp tài liệu. Nội dung nhận được nằm ở Path nếu đã được cách ly
+type InvalidContentError struct {
+	URL    string
+	Reason string
+	Path   string // đường dẫn tệp trong thư mục cách ly, rỗng nếu nội dung đã bị xóa
+}
+
+func (e *InvalidContentError) Error() string {
+	return fmt.Sprintf("nội dung không hợp lệ: %s", e.Reason)
+}
+
+// DetectErrorPage kiểm tra tệp path có phải trang HTML hoặc trang lỗi thay cho tệp name hay không.
+// contentType là header Content-Type của phản hồi (có thể rỗng). Trả về lý do, rỗng nếu tệp hợp lệ.
+// Tệp có phần mở rộng .htm, .html hoặc .txt không bị kiểm tra
+func DetectErrorPage(path, contentType, name string) (string, error) {
+	if textExtensions[strings.ToLower(filepath.Ext(name))] {
+		return "", nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return DetectErrorPageReader(file, contentType, name)
+}
+
+// DetectErrorPageReader giống DetectErrorPage nhưng đọc nội dung từ r (chỉ đọc phần đầu),
+// dùng cho tệp trong kho lưu trữ không nằm trên máy
+func DetectErrorPageReader(r io.Reader, contentType, name string) (string, error) {
+	if textExtensions[strings.ToLower(filepath.Ext(name))] {
+		return "", nil
+	}
+
+	head := make([]byte, errorPageSniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+
+	sniffed := http.DetectContentType(head)
+	declaredHTML := strings.HasPrefix(strings.ToLower(contentType), "text/html")
+	isHTML := strings.HasPrefix(sniffed, "text/html") || (declaredHTML && strings.HasPrefix(sniffed, "text/"))
+	if !strings.HasPrefix(sniffed, "text/") {
+		// Tệp nhị phân (PDF, Office...) có thể chứa chuỗi trùng với dấu hiệu nên không kiểm tra tiếp
+		return "", nil
+	}
+
+	// Báo cáo dạng văn bản (CSV, XML...) có thể chứa chuỗi trùng dấu hiệu nên chỉ kiểm tra dấu hiệu
+	// khi phản hồi là HTML hoặc tệp mong đợi không phải văn bản (ví dụ PDF)
+	if !isHTML && !expectsBinary(name) {
+		return "", nil
+	}
+
+	lower := bytes.ToLower(head)
+	for _, m := range errorPageMarkers {
+		if bytes.Contains(lower, []byte(m.marker)) {
+			if isHTML {
+				return fmt.Sprintf("máy chủ trả về trang HTML (%s) thay cho tệp", m.reason), nil
+			}
+			return fmt.Sprintf("máy chủ trả về %s thay cho tệp", m.reason), nil
+		}
+	}
+	if isHTML {
+		return "máy chủ trả về trang HTML thay cho tệp", nil
+	}
+
+	return "", nil
+}
+
+// expectsBinary cho biết tệp name theo phần mở rộng là tệp nhị phân (PDF, Office, ảnh...),
+// phần mở rộng không xác định được coi là không biết
+func expectsBinary(name string) bool {
+	t := typeForExtension(filepath.Ext(name))
+	switch {
+	case t == "", strings.HasPrefix(t, "text/"):
+		return false
+	case strings.HasSuffix(t, "/xml"), strings.HasSuffix(t, "+xml"), strings.HasSuffix(t, "/json"):
+		return false
+	default:
+		return true
+	}
+}
+
+// quarantine chuyển tệp tạm tmpPath vào thư mục cách ly, giữ tên tài liệu kèm phần mở rộng
+// theo kiểu nội dung. Tệp tạm bị xóa nếu không đặt thư mục cách ly
+func (d *Downloader) quarantine(tmpPath, destPath, mimeType string) (string, error) {
+	if d.QuarantineDir == "" {
+		return "", os.Remove(tmpPath)
+	}
+
+	// Giữ thư mục danh mục để tránh trùng tên giữa các danh mục
+	dir := filepath.Join(d.QuarantineDir, filepath.Base(filepath.Dir(destPath)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	ext := extensionForType(mimeType)
+	if ext == "" {
+		ext = ".html"
+	}
+	path := filepath.Join(dir, filepath.Base(destPath)+ext)
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	return path, nil
+}
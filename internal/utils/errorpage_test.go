@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectErrorPageReader(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		file        string
+		wantErrPage bool
+	}{
+		{
+			name:        "html error page instead of pdf",
+			body:        "<html><body><h1>Server Error in '/' Application.</h1></body></html>",
+			contentType: "text/html; charset=utf-8",
+			file:        "BCTC 2024.pdf",
+			wantErrPage: true,
+		},
+		{
+			name:        "plain text error instead of pdf",
+			body:        "Runtime Error: An application error occurred on the server.",
+			contentType: "text/plain",
+			file:        "BCTC 2024.pdf",
+			wantErrPage: true,
+		},
+		{
+			name:        "html declared for csv",
+			body:        "Access denied",
+			contentType: "text/html",
+			file:        "Danh sách cổ đông.csv",
+			wantErrPage: true,
+		},
+		{
+			name:        "plain text csv mentioning access denied",
+			body:        "Ngày,Sự kiện\n01/02/2025,Access denied khi truy cập hệ thống kế toán\n",
+			contentType: "text/csv",
+			file:        "Nhật ký.csv",
+			wantErrPage: false,
+		},
+		{
+			name:        "plain text document mentioning access denied",
+			body:        "Báo cáo sự cố: người dùng nhận thông báo access denied trong 2 giờ.",
+			contentType: "text/plain",
+			file:        "Báo cáo sự cố",
+			wantErrPage: false,
+		},
+		{
+			name:        "xml report mentioning runtime error",
+			body:        `<?xml version="1.0"?><report><item>Runtime error rate 0.1%</item></report>`,
+			contentType: "application/xml",
+			file:        "Báo cáo.xml",
+			wantErrPage: false,
+		},
+		{
+			name:        "pdf containing a marker",
+			body:        "%PDF-1.4 access denied",
+			contentType: "application/pdf",
+			file:        "BCTC 2024.pdf",
+			wantErrPage: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, err := DetectErrorPageReader(strings.NewReader(tt.body), tt.contentType, tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (reason != "") != tt.wantErrPage {
+				t.Errorf("DetectErrorPageReader = %q, want error page %v", reason, tt.wantErrPage)
+			}
+		})
+	}
+}
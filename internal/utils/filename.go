@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DownloadResult mô tả tệp đã tải: tên được chọn, tên gốc của máy chủ và kiểu MIME
+type DownloadResult struct {
+	Path           string `json:"path"`                       // đường dẫn đầy đủ của tệp đã lưu
+	FileName       string `json:"file_name"`                  // tên tệp được chọn (phần cuối của Path)
+	ServerFileName string `json:"server_file_name,omitempty"` // tên trong Content-Disposition, rỗng nếu không có
+	MIMEType       string `json:"mime_type,omitempty"`        // kiểu MIME xác định từ nội dung tệp
+	Size           int64  `json:"size"`
+	SHA256         string `json:"sha256"` // mã băm SHA-256 (hex) của nội dung
+
+	// Header ETag và Last-Modified của phản hồi, dùng để hỏi lại máy chủ tệp đã đổi hay chưa (xem Downloader.Changed)
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// extensionTypes là kiểu MIME của các phần mở rộng thường gặp trên trang tài liệu.
+// Bảng MIME của hệ thống có thể thiếu các định dạng Office nên được khai báo tại đây
+var extensionTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".zip":  "application/zip",
+	".rar":  "application/x-rar-compressed",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".txt":  "text/plain",
+	".htm":  "text/html",
+	".html": "text/html",
+}
+
+// preferredExtensions là phần mở rộng được gán cho từng kiểu MIME khi tệp chưa có phần mở rộng đúng
+var preferredExtensions = map[string]string{
+	"application/pdf":              ".pdf",
+	"application/msword":           ".doc",
+	"application/vnd.ms-excel":     ".xls",
+	"application/zip":              ".zip",
+	"application/x-rar-compressed": ".rar",
+	"image/jpeg":                   ".jpg",
+	"image/png":                    ".png",
+	"image/gif":                    ".gif",
+	"text/html":                    ".html",
+	"text/plain":                   ".txt",
+}
+
+// zipBasedExtensions là các định dạng lưu dưới dạng ZIP, http.DetectContentType nhận là application/zip
+var zipBasedExtensions = map[string]bool{".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".ods": true, ".zip": true}
+
+// dispositionFilename khớp tham số filename khi Content-Disposition không đúng cú pháp MIME
+var dispositionFilename = regexp.MustCompile(`(?i)filename\s*=\s*"?([^";]+)"?`)
+
+// ContentDispositionFilename trả về tên tệp trong header Content-Disposition, hỗ trợ dạng
+// filename*=UTF-8”... (RFC 5987) cho tên tiếng Việt. Trả về chuỗi rỗng nếu không có
+func ContentDispositionFilename(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	var name string
+	if _, params, err := mime.ParseMediaType(header); err == nil {
+		name = params["filename"]
+	} else if m := dispositionFilename.FindStringSubmatch(header); m != nil {
+		// Máy chủ ASP.NET đôi khi gửi tên không đặt trong dấu ngoặc kép
+		name = strings.TrimSpace(m[1])
+	}
+
+	// Một số máy chủ mã hóa URL tên tệp thay vì dùng filename*
+	if strings.Contains(name, "%") {
+		if decoded, err := url.PathUnescape(name); err == nil {
+			name = decoded
+		}
+	}
+
+	// Bỏ phần thư mục nếu máy chủ gửi kèm đường dẫn
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+
+	return SanitizeFileName(name)
+}
+
+// MaxFileNameBytes là độ dài tối đa (byte) của tên tệp, chừa chỗ cho hậu tố ".tmp.meta"
+// khi đang tải và giữ đường dẫn đầy đủ dưới giới hạn 260 ký tự của Windows
+const MaxFileNameBytes = 150
+
+// invalidFileNameChars là các ký tự không được phép trong tên tệp trên Windows, macOS hoặc Linux
+const invalidFileNameChars = `/\:*?"<>|`
+
+// reservedFileNames là các tên thiết bị mà Windows không cho phép dùng làm tên tệp
+var reservedFileNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// SanitizeFileName tạo tên tệp an toàn trên mọi hệ thống tệp: Unicode dạng NFC, bỏ ký tự
+// điều khiển, thay ký tự không hợp lệ (kể cả "/" và "\") bằng "_", tránh các tên thiết bị
+// dành riêng của Windows và cắt còn MaxFileNameBytes, giữ phần mở rộng
+func SanitizeFileName(name string) string {
+	name = norm.NFC.String(name)
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == utf8.RuneError, unicode.Is(unicode.Cf, r):
+			// Bỏ ký tự hỏng và ký tự định dạng vô hình (ví dụ U+200B, U+FEFF)
+		case unicode.IsControl(r):
+			// Xuống dòng, tab... trong thuộc tính title thành khoảng trắng
+			b.WriteRune(' ')
+		case strings.ContainsRune(invalidFileNameChars, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	name = strings.Join(strings.Fields(b.String()), " ")
+
+	stem := strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+	if reservedFileNames[stem] {
+		name = "_" + name
+	}
+
+	return TruncateFileName(name, "")
+}
+
+// TruncateFileName chèn suffix trước phần mở rộng và cắt phần tên (không cắt đôi ký tự UTF-8)
+// để cả tên dài tối đa MaxFileNameBytes. Dấu chấm và khoảng trắng cuối tên bị bỏ vì Windows
+// không cho phép
+func TruncateFileName(name, suffix string) string {
+	ext := path.Ext(name)
+	if ext == "." || len(ext) > 16 || strings.ContainsRune(ext, ' ') {
+		// Dấu chấm cuối hoặc giữa câu, không phải phần mở rộng
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	limit := MaxFileNameBytes - len(ext) - len(suffix)
+	if limit < 0 {
+		limit = 0
+	}
+	if len(base) > limit {
+		for limit > 0 && !utf8.RuneStart(base[limit]) {
+			limit--
+		}
+		base = base[:limit]
+	}
+
+	base = strings.TrimRight(base, " .")
+	if base == "" && suffix == "" && ext == "" {
+		return ""
+	}
+	if base == "" {
+		base = "_"
+	}
+
+	return base + suffix + ext
+}
+
+// SniffMIME xác định kiểu MIME từ 512 byte đầu của tệp. Khi nội dung không đủ đặc trưng,
+// dùng kiểu trong header Content-Type (nếu có)
+func SniffMIME(path, contentType string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	declared, _, _ := mime.ParseMediaType(contentType)
+	if (sniffed == "application/octet-stream" || sniffed == "text/plain") && declared != "" && declared != "application/octet-stream" {
+		return declared, nil
+	}
+	return sniffed, nil
+}
+
+// typeForExtension trả về kiểu MIME của phần mở rộng, rỗng nếu không biết
+func typeForExtension(ext string) string {
+	ext = strings.ToLower(ext)
+	if t, ok := extensionTypes[ext]; ok {
+		return t
+	}
+	t, _, _ := mime.ParseMediaType(mime.TypeByExtension(ext))
+	return t
+}
+
+// extensionForType trả về phần mở rộng ưu tiên của kiểu MIME, rỗng nếu không biết
+func extensionForType(mimeType string) string {
+	if ext, ok := preferredExtensions[mimeType]; ok {
+		return ext
+	}
+	for ext, t := range extensionTypes {
+		if t == mimeType {
+			return ext
+		}
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// FixExtension trả về name với phần mở rộng phù hợp mimeType: thêm khi thiếu, thay khi mâu thuẫn
+// với nội dung. Kiểu không đặc trưng (application/octet-stream) giữ nguyên tên
+func FixExtension(name, mimeType string) string {
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	lower := strings.ToLower(ext)
+	switch {
+	case mimeType == "application/zip" && zipBasedExtensions[lower]:
+		return name
+	case ext != "" && typeForExtension(ext) == mimeType:
+		return name
+	case mimeType == "text/plain" && ext != "":
+		// Văn bản thuần có thể là CSV, XML... không đổi phần mở rộng đã có
+		return name
+	}
+
+	want := extensionForType(mimeType)
+	if want == "" {
+		return name
+	}
+	if ext == "" || typeForExtension(ext) == "" {
+		// Không có phần mở rộng hoặc phần sau dấu chấm chỉ là một phần của tên
+		return name + want
+	}
+	return strings.TrimSuffix(name, ext) + want
+}
+
+// LocalFileInfo mô tả một tệp đã có trên đĩa (không có thông tin từ máy chủ)
+func LocalFileInfo(path string) (*DownloadResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	mimeType, err := SniffMIME(path, "")
+	if err != nil {
+		return nil, err
+	}
+	sum, _, err := HashFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DownloadResult{
+		Path:     path,
+		FileName: filepath.Base(path),
+		MIMEType: mimeType,
+		Size:     info.Size(),
+		SHA256:   sum,
+	}, nil
+}
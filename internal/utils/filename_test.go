@@ -0,0 +1,49 @@
+package utils
+
+import "testing"
+
+func TestContentDispositionFilename(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"empty", "", ""},
+		{"no filename", "attachment", ""},
+		{"inline without filename", "inline; size=42", ""},
+		{"quoted", `attachment; filename="BCTC 2024.pdf"`, "BCTC 2024.pdf"},
+		{"unquoted", "attachment; filename=BCTC_2024.pdf", "BCTC_2024.pdf"},
+		{"unquoted with spaces", "attachment; filename=BCTC 2024.pdf", "BCTC 2024.pdf"},
+		{"uppercase parameter", `Attachment; FILENAME="A.pdf"`, "A.pdf"},
+		{
+			name:   "rfc 5987",
+			header: "attachment; filename*=UTF-8''B%C3%A1o%20c%C3%A1o%20t%C3%A0i%20ch%C3%ADnh.pdf",
+			want:   "Báo cáo tài chính.pdf",
+		},
+		{
+			name:   "rfc 5987 wins over ascii fallback",
+			header: `attachment; filename="Bao cao.pdf"; filename*=UTF-8''B%C3%A1o%20c%C3%A1o.pdf`,
+			want:   "Báo cáo.pdf",
+		},
+		{
+			name:   "rfc 5987 decomposed unicode is normalized",
+			header: "attachment; filename*=UTF-8''Ba%CC%81o%20ca%CC%81o.pdf",
+			want:   "Báo cáo.pdf",
+		},
+		{"percent encoded", `attachment; filename="Ngh%E1%BB%8B%20quy%E1%BA%BFt.pdf"`, "Nghị quyết.pdf"},
+		{"invalid percent encoding kept", `attachment; filename="100%.pdf"`, "100%.pdf"},
+		{"unix path stripped", `attachment; filename="../../etc/passwd"`, "passwd"},
+		{"windows path stripped", `attachment; filename="C:\\Temp\\BCTC.pdf"`, "BCTC.pdf"},
+		{"only directory", `attachment; filename="/"`, ""},
+		{"invalid characters replaced", `attachment; filename="Q1: BCTC?.pdf"`, "Q1_ BCTC_.pdf"},
+		{"reserved windows name", `attachment; filename="CON.pdf"`, "_CON.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContentDispositionFilename(tt.header); got != tt.want {
+				t.Errorf("ContentDispositionFilename(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// clock trừu tượng hóa thời gian cho bộ giới hạn tốc độ và chính sách thử lại để kiểm thử
+// không phải chờ thật
+type clock interface {
+	Now() time.Time
+	// Sleep chờ d hoặc tới khi ctx bị hủy (trả về ctx.Err())
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// realClock là đồng hồ thật
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RateLimiter là bộ giới hạn tốc độ kiểu token bucket, an toàn khi dùng đồng thời.
+// Con trỏ nil nghĩa là không giới hạn
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Số token nạp lại mỗi giây
+	burst  float64 // Số token tối đa trong bucket
+	tokens float64
+	last   time.Time
+	clock  clock
+}
+
+// NewRateLimiter tạo bộ giới hạn rps yêu cầu/giây với burst yêu cầu liên tiếp.
+// Trả về nil (không giới hạn) nếu rps <= 0
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return newRateLimiter(rps, burst, realClock{})
+}
+
+func newRateLimiter(rps float64, burst int, clk clock) *RateLimiter {
+	return &RateLimiter{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clk.Now(),
+		clock:  clk,
+	}
+}
+
+// Wait chờ đến khi có token hoặc ctx bị hủy
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	l.refill()
+
+	// Đặt trước một token, nếu bucket âm thì phải chờ phần thiếu được nạp lại
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+
+	if err := l.clock.Sleep(ctx, wait); err != nil {
+		// Trả lại token đã đặt trước
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// refill nạp token theo thời gian trôi qua từ lần trước, phải gọi khi đang giữ mu
+func (l *RateLimiter) refill() {
+	now := l.clock.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+// SetRate thay đổi tốc độ nạp token, ví dụ khi robots.txt yêu cầu Crawl-delay.
+// Token của khoảng thời gian đã qua được tính theo tốc độ cũ
+func (l *RateLimiter) SetRate(rps float64) {
+	if l == nil || rps <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.refill()
+	l.rate = rps
+	l.mu.Unlock()
+}
+
+// Rate trả về tốc độ hiện tại (yêu cầu/giây), 0 nếu không giới hạn
+func (l *RateLimiter) Rate() float64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// HostLimiter giới hạn số yêu cầu đồng thời tới mỗi host.
+// Con trỏ nil nghĩa là không giới hạn
+type HostLimiter struct {
+	mu   sync.Mutex
+	max  int
+	sems map[string]chan struct{}
+}
+
+// NewHostLimiter tạo bộ giới hạn max yêu cầu đồng thời cho mỗi host, nil nếu max <= 0
+func NewHostLimiter(max int) *HostLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &HostLimiter{
+		max:  max,
+		sems: make(map[string]chan struct{}),
+	}
+}
+
+// Acquire chiếm một chỗ cho host, trả về hàm giải phóng phải được gọi sau khi xong
+func (h *HostLimiter) Acquire(ctx context.Context, host string) (func(), error) {
+	if h == nil {
+		return func() {}, ctx.Err()
+	}
+
+	h.mu.Lock()
+	sem, ok := h.sems[host]
+	if !ok {
+		sem = make(chan struct{}, h.max)
+		h.sems[host] = sem
+	}
+	h.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock là đồng hồ giả: Sleep tiến thời gian ngay lập tức và ghi lại thời gian chờ
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 17, 8, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	return nil
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Sleeps trả về các lần chờ đã ghi và xóa danh sách
+func (c *fakeClock) Sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sleeps := c.sleeps
+	c.sleeps = nil
+	return sleeps
+}
+
+func TestRateLimiter(t *testing.T) {
+	clk := newFakeClock()
+	limiter := newRateLimiter(2, 3, clk)
+	ctx := context.Background()
+
+	// Bucket đầy cho phép burst yêu cầu liên tiếp không phải chờ
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sleeps := clk.Sleeps(); len(sleeps) != 0 {
+		t.Fatalf("burst waited %v", sleeps)
+	}
+
+	// Hết token: mỗi yêu cầu chờ 1/rate giây
+	for i := 0; i < 2; i++ {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sleeps := clk.Sleeps(); len(sleeps) != 2 || sleeps[0] != 500*time.Millisecond || sleeps[1] != 500*time.Millisecond {
+		t.Errorf("sleeps after burst = %v, want two of 500ms", sleeps)
+	}
+
+	// Sau thời gian nghỉ dài, bucket chỉ nạp lại tối đa burst token
+	clk.Advance(time.Minute)
+	for i := 0; i < 4; i++ {
+		limiter.Wait(ctx)
+	}
+	if sleeps := clk.Sleeps(); len(sleeps) != 1 || sleeps[0] != 500*time.Millisecond {
+		t.Errorf("sleeps after idle = %v, want one of 500ms", sleeps)
+	}
+
+	// Crawl-delay làm chậm tốc độ
+	limiter.SetRate(0.5)
+	limiter.Wait(ctx)
+	if sleeps := clk.Sleeps(); len(sleeps) != 1 || sleeps[0] != 2*time.Second {
+		t.Errorf("sleeps after SetRate(0.5) = %v, want 2s", sleeps)
+	}
+	if limiter.Rate() != 0.5 {
+		t.Errorf("Rate() = %v, want 0.5", limiter.Rate())
+	}
+}
+
+func TestRateLimiterCanceled(t *testing.T) {
+	clk := newFakeClock()
+	limiter := newRateLimiter(1, 1, clk)
+	limiter.Wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err != context.Canceled {
+		t.Fatalf("Wait on canceled context = %v", err)
+	}
+
+	// Token đặt trước được trả lại nên lượt sau chỉ chờ một khoảng
+	limiter.Wait(context.Background())
+	if sleeps := clk.Sleeps(); len(sleeps) != 1 || sleeps[0] != time.Second {
+		t.Errorf("sleeps after canceled wait = %v, want 1s", sleeps)
+	}
+}
+
+func TestNilLimiters(t *testing.T) {
+	if NewRateLimiter(0, 1) != nil || NewHostLimiter(0) != nil {
+		t.Fatal("zero limits should disable limiting")
+	}
+	var limiter *RateLimiter
+	if err := limiter.Wait(context.Background()); err != nil || limiter.Rate() != 0 {
+		t.Errorf("nil RateLimiter: Wait = %v, Rate = %v", err, limiter.Rate())
+	}
+	var hosts *HostLimiter
+	release, err := hosts.Acquire(context.Background(), "netcovn.com.vn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestHostLimiter(t *testing.T) {
+	hosts := NewHostLimiter(2)
+	ctx := context.Background()
+
+	first, _ := hosts.Acquire(ctx, "netcovn.com.vn")
+	second, _ := hosts.Acquire(ctx, "netcovn.com.vn")
+
+	// Host khác không bị ảnh hưởng
+	other, err := hosts.Acquire(ctx, "cdn.netcovn.com.vn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	// Chỗ thứ ba của cùng host phải chờ tới khi có chỗ được giải phóng
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := hosts.Acquire(timeout, "netcovn.com.vn"); err != context.DeadlineExceeded {
+		t.Fatalf("third Acquire = %v, want DeadlineExceeded", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, _ := hosts.Acquire(ctx, "netcovn.com.vn")
+		acquired <- release
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("third Acquire succeeded while the host was full")
+	case <-time.After(10 * time.Millisecond):
+	}
+	first()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("third Acquire not granted after release")
+	}
+	second()
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// partialDownload là thông tin lưu cạnh tệp tạm (destPath + ".tmp.meta") để tiếp tục tải bằng Range.
+// Chỉ được ghi khi máy chủ cung cấp ETag mạnh hoặc Last-Modified dùng cho If-Range
+type partialDownload struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Size         int64  `json:"size,omitempty"` // tổng kích thước tệp, 0 nếu không biết
+}
+
+// newPartialDownload trả về thông tin tiếp tục tải từ phản hồi 200, nil nếu không thể tiếp tục
+func newPartialDownload(url string, resp *http.Response) *partialDownload {
+	if strings.EqualFold(resp.Header.Get("Accept-Ranges"), "none") {
+		return nil
+	}
+
+	// ETag yếu không dùng được cho If-Range (RFC 9110, mục 13.1.5)
+	etag := resp.Header.Get("ETag")
+	if strings.HasPrefix(etag, "W/") {
+		etag = ""
+	}
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return nil
+	}
+
+	p := &partialDownload{URL: url, ETag: etag, LastModified: lastModified}
+	if resp.ContentLength > 0 {
+		p.Size = resp.ContentLength
+	}
+	return p
+}
+
+// loadPartialDownload đọc thông tin tiếp tục tải của tmpPath và số byte đã có.
+// Trả về nil nếu không có tệp tạm hợp lệ cho url
+func loadPartialDownload(tmpPath, url string) (*partialDownload, int64) {
+	data, err := os.ReadFile(tmpPath + ".meta")
+	if err != nil {
+		return nil, 0
+	}
+
+	var p partialDownload
+	if err := json.Unmarshal(data, &p); err != nil || p.URL != url || p.validator() == "" {
+		return nil, 0
+	}
+
+	info, err := os.Stat(tmpPath)
+	if err != nil || info.Size() == 0 || (p.Size > 0 && info.Size() > p.Size) {
+		return nil, 0
+	}
+
+	return &p, info.Size()
+}
+
+// save ghi thông tin tiếp tục tải cạnh tệp tạm
+func (p *partialDownload) save(tmpPath string) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tmpPath+".meta", data, 0644)
+}
+
+// validator trả về giá trị dùng cho If-Range, ưu tiên ETag
+func (p *partialDownload) validator() string {
+	if p.ETag != "" {
+		return p.ETag
+	}
+	return p.LastModified
+}
+
+// setRangeHeaders yêu cầu phần còn lại của tệp kể từ offset
+func (p *partialDownload) setRangeHeaders(req *http.Request, offset int64) {
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	req.Header.Set("If-Range", p.validator())
+}
+
+// validate kiểm tra phản hồi 206 đúng là phần tiếp theo của tệp đang tải
+func (p *partialDownload) validate(resp *http.Response, offset int64) error {
+	if etag := resp.Header.Get("ETag"); p.ETag != "" && etag != "" && etag != p.ETag {
+		return fmt.Errorf("ETag thay đổi từ %s thành %s", p.ETag, etag)
+	}
+
+	start, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return err
+	}
+	if start != offset {
+		return fmt.Errorf("Content-Range bắt đầu từ %d thay vì %d", start, offset)
+	}
+	if p.Size > 0 && total >= 0 && total != p.Size {
+		return fmt.Errorf("kích thước tệp thay đổi từ %d thành %d", p.Size, total)
+	}
+	return nil
+}
+
+// complete cho biết phản hồi 416 xác nhận tệp tạm đã đủ kích thước
+func (p *partialDownload) complete(resp *http.Response, offset int64) bool {
+	_, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	return err == nil && total == offset && (p.Size == 0 || p.Size == offset)
+}
+
+// parseContentRange phân tích header "bytes start-end/total" hoặc "bytes */total".
+// Giá trị không xác định ("*") được trả về là -1
+func parseContentRange(header string) (start, end, total int64, err error) {
+	unit, spec, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || unit != "bytes" {
+		return 0, 0, 0, fmt.Errorf("Content-Range không hợp lệ: %q", header)
+	}
+
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("Content-Range không hợp lệ: %q", header)
+	}
+
+	start, end, total = -1, -1, -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil || total < 0 {
+			return 0, 0, 0, fmt.Errorf("Content-Range không hợp lệ: %q", header)
+		}
+	}
+	if rng == "*" {
+		return start, end, total, nil
+	}
+
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("Content-Range không hợp lệ: %q", header)
+	}
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	end, err2 := strconv.ParseInt(last, 10, 64)
+	if err := errors.Join(err1, err2); err != nil || start > end || (total >= 0 && end >= total) {
+		return 0, 0, 0, fmt.Errorf("Content-Range không hợp lệ: %q", header)
+	}
+
+	return start, end, total, nil
+}
+
+// removePartialDownload xóa tệp tạm và thông tin tiếp tục tải
+func removePartialDownload(tmpPath string) {
+	os.Remove(tmpPath)
+	os.Remove(tmpPath + ".meta")
+}
@@ -0,0 +1,43 @@
+package utils
+
+import "testing"
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header            string
+		start, end, total int64
+		wantErr           bool
+	}{
+		{"bytes 0-499/1234", 0, 499, 1234, false},
+		{"bytes 500-1233/1234", 500, 1233, 1234, false},
+		{" bytes 500-1233/* ", 500, 1233, -1, false},
+		{"bytes */1234", -1, -1, 1234, false},
+		{"bytes */*", -1, -1, -1, false},
+		{"bytes 0-0/1", 0, 0, 1, false},
+		{"", 0, 0, 0, true},
+		{"bytes", 0, 0, 0, true},
+		{"items 0-499/1234", 0, 0, 0, true},
+		{"bytes 0-499", 0, 0, 0, true},
+		{"bytes 500-499/1234", 0, 0, 0, true},
+		{"bytes 0-1234/1234", 0, 0, 0, true},
+		{"bytes -1-499/1234", 0, 0, 0, true},
+		{"bytes a-b/1234", 0, 0, 0, true},
+		{"bytes 0-499/-5", 0, 0, 0, true},
+		{"bytes 0-499/x", 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		start, end, total, err := parseContentRange(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseContentRange(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if start != tt.start || end != tt.end || total != tt.total {
+			t.Errorf("parseContentRange(%q) = %d, %d, %d, want %d, %d, %d",
+				tt.header, start, end, total, tt.start, tt.end, tt.total)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy mô tả chính sách thử lại với backoff lũy thừa và jitter
+type RetryPolicy struct {
+	MaxAttempts     int           // Tổng số lần thử, kể cả lần đầu tiên
+	BaseDelay       time.Duration // Thời gian chờ trước lần thử lại đầu tiên
+	MaxDelay        time.Duration // Giới hạn trên của thời gian chờ do backoff
+	Jitter          float64       // Tỉ lệ dao động ngẫu nhiên (0..1) quanh thời gian chờ
+	RetryableStatus []int         // Các mã trạng thái HTTP được phép thử lại
+
+	clock clock // nil dùng đồng hồ thật
+}
+
+// DefaultRetryPolicy trả về chính sách thử lại mặc định cho trang danh sách và tệp tải xuống
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+		RetryableStatus: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooEarly,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// StatusError là lỗi khi máy chủ trả về mã trạng thái không thành công
+type StatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration // Giá trị của header Retry-After nếu có
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("phản hồi lỗi: %s", e.Status)
+}
+
+// NewStatusError tạo StatusError từ phản hồi HTTP, kèm phân tích header Retry-After
+func NewStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter phân tích Retry-After dạng số giây hoặc ngày HTTP (tính từ thời điểm now)
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// IsRetryable cho biết lỗi có phải lỗi tạm thời đáng thử lại hay không
+func (p RetryPolicy) IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		for _, code := range p.RetryableStatus {
+			if statusErr.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Lỗi mạng (timeout, kết nối bị đóng...) và phản hồi bị cắt ngang đều là tạm thời
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Backoff tính thời gian chờ trước lần thử tiếp theo (attempt bắt đầu từ 1).
+// Nếu máy chủ gửi Retry-After thì giá trị đó được ưu tiên khi lớn hơn backoff
+func (p RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 && delay > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+		delay = statusErr.RetryAfter
+	}
+
+	return delay
+}
+
+// Retry thực thi op theo chính sách thử lại cho đến khi thành công,
+// gặp lỗi không thể thử lại, hết số lần thử hoặc ctx bị hủy
+func Retry(ctx context.Context, p RetryPolicy, op func(attempt int) error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	attempts := 0
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		attempts = attempt
+		err = op(attempt)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !p.IsRetryable(err) || attempt == maxAttempts {
+			break
+		}
+
+		clk := p.clock
+		if clk == nil {
+			clk = realClock{}
+		}
+		if err := clk.Sleep(ctx, p.Backoff(attempt, err)); err != nil {
+			return err
+		}
+	}
+
+	if attempts > 1 {
+		return fmt.Errorf("thất bại sau %d lần thử: %w", attempts, err)
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 17, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{"-5", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0},
+		{"ngày mai", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := p.Backoff(attempt, nil); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	// Retry-After lớn hơn backoff được ưu tiên, nhỏ hơn thì bỏ qua
+	if got := p.Backoff(1, &StatusError{StatusCode: 429, RetryAfter: 30 * time.Second}); got != 30*time.Second {
+		t.Errorf("Backoff with Retry-After 30s = %v", got)
+	}
+	if got := p.Backoff(3, &StatusError{StatusCode: 503, RetryAfter: time.Second}); got != 4*time.Second {
+		t.Errorf("Backoff with short Retry-After = %v, want 4s", got)
+	}
+
+	// Jitter giữ thời gian chờ trong khoảng cho phép
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(2, nil); got < 1600*time.Millisecond || got > 2400*time.Millisecond {
+			t.Fatalf("Backoff with jitter = %v, want 2s ± 20%%", got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	unavailable := &StatusError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}
+	tests := []struct {
+		name         string
+		errs         []error // lỗi của từng lần thử, hết danh sách thì thành công
+		wantAttempts int
+		wantSleeps   []time.Duration
+		wantErr      string
+	}{
+		{name: "success", wantAttempts: 1},
+		{
+			name:         "retry then success",
+			errs:         []error{unavailable, io.ErrUnexpectedEOF},
+			wantAttempts: 3,
+			wantSleeps:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:         "max attempts",
+			errs:         []error{unavailable, unavailable, unavailable, unavailable},
+			wantAttempts: 3,
+			wantSleeps:   []time.Duration{time.Second, 2 * time.Second},
+			wantErr:      "thất bại sau 3 lần thử",
+		},
+		{
+			name:         "retry after",
+			errs:         []error{&StatusError{StatusCode: http.StatusTooManyRequests, Status: "429", RetryAfter: time.Minute}},
+			wantAttempts: 2,
+			wantSleeps:   []time.Duration{time.Minute},
+		},
+		{
+			name:         "not retryable",
+			errs:         []error{&StatusError{StatusCode: http.StatusNotFound, Status: "404 Not Found"}},
+			wantAttempts: 1,
+			wantErr:      "404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := newFakeClock()
+			p := RetryPolicy{
+				MaxAttempts:     3,
+				BaseDelay:       time.Second,
+				MaxDelay:        10 * time.Second,
+				RetryableStatus: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+				clock:           clk,
+			}
+
+			attempts := 0
+			err := Retry(context.Background(), p, func(attempt int) error {
+				attempts = attempt
+				if attempt <= len(tt.errs) {
+					return tt.errs[attempt-1]
+				}
+				return nil
+			})
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if sleeps := clk.Sleeps(); len(sleeps) != len(tt.wantSleeps) || (len(sleeps) > 0 && !equalDurations(sleeps, tt.wantSleeps)) {
+				t.Errorf("sleeps = %v, want %v", sleeps, tt.wantSleeps)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Retry = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Retry = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, RetryableStatus: []int{503}, clock: newFakeClock()}
+
+	attempts := 0
+	err := Retry(ctx, p, func(int) error {
+		attempts++
+		cancel()
+		return &StatusError{StatusCode: 503}
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("Retry after cancel = %v after %d attempts", err, attempts)
+	}
+}
+
+func equalDurations(a, b []time.Duration) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -41,8 +41,18 @@ document.addEventListener('DOMContentLoaded', function() {
             
             // Sắp xếp các hàng
             rows.sort((a, b) => {
-                const aValue = a.querySelector(`td:nth-child(${column})`).textContent.trim();
-                const bValue = b.querySelector(`td:nth-child(${column})`).textContent.trim();
+                const aCell = a.querySelector(`td:nth-child(${column})`);
+                const bCell = b.querySelector(`td:nth-child(${column})`);
+                
+                // Ưu tiên giá trị số đã phân tích (byte, lượt tải, thời điểm) nếu có
+                if (aCell.dataset.sortValue !== undefined && bCell.dataset.sortValue !== undefined) {
+                    const aNum = parseFloat(aCell.dataset.sortValue) || 0;
+                    const bNum = parseFloat(bCell.dataset.sortValue) || 0;
+                    return direction === 'asc' ? aNum - bNum : bNum - aNum;
+                }
+                
+                const aValue = aCell.textContent.trim();
+                const bValue = bCell.textContent.trim();
                 
                 return direction === 'asc' ? aValue.localeCompare(bValue) : bValue.localeCompare(aValue);
             });